@@ -1,11 +1,18 @@
 package dcmd
 
 import (
+	"fmt"
 	"github.com/jonas747/discordgo"
 	"github.com/jonas747/dstate"
 	"github.com/jonas747/dutil"
+	"github.com/pkg/errors"
+	"math"
+	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // ArgDef represents a argument definition, either a switch or plain arg
@@ -15,6 +22,89 @@ type ArgDef struct {
 	Type    ArgType
 	Help    string
 	Default interface{}
+
+	// Other names this switch can be used with, e.g a short version of Switch
+	SwitchAliases []string
+
+	// Variadic args accept 1 or more values of Type, the parsed value will be a []interface{}
+	// For switches this means the switch can be repeated to provide multiple values
+	Variadic bool
+
+	// Switch relations, these only apply to switches
+	// Required switches have to be provided
+	Required bool
+	// Only one of the switches with the same ExclusiveGroup can be provided
+	ExclusiveGroup string
+	// Requires is a list of other switches (by Switch name or alias) that have to be provided if this one is
+	Requires []string
+
+	// Validators are ran in order after the value has been parsed, for variadic args they're ran for each value
+	// They should return user errors (see NewSimpleUserError) if the value is not allowed
+	Validators []ArgValidator
+
+	// DefaultFunc is called to get the value if the arg was omitted, it takes priority over Default
+	DefaultFunc func(data *Data) (interface{}, error)
+	// Describes the default in help, e.g "you" or "this channel"
+	DefaultHelp string
+
+	// FromReply takes the value from the referenced message if the arg is omitted and the command was ran as a reply, see ParseArgDefs
+	FromReply ReplySource
+}
+
+// SetDefault sets the value of p to the default of def, calling DefaultFunc if set
+func (a *ArgDef) SetDefault(data *Data, p *ParsedArg) error {
+	if a.DefaultFunc == nil {
+		p.Value = a.Default
+		return nil
+	}
+
+	val, err := a.DefaultFunc(data)
+	if err != nil {
+		return err
+	}
+
+	p.Value = val
+	return nil
+}
+
+// DefaultInvoker can be used as a ArgDef.DefaultFunc for user args, it returns the user that invoked the command as a *AdvUserMatch
+func DefaultInvoker(data *Data) (interface{}, error) {
+	match := &AdvUserMatch{User: data.Msg.Author}
+	if data.GS != nil {
+		match.Member = data.GS.MemberCopy(true, data.Msg.Author.ID)
+	}
+
+	return match, nil
+}
+
+// DefaultCurrentChannel can be used as a ArgDef.DefaultFunc for channel args, it returns the channel the command was invoked in
+func DefaultCurrentChannel(data *Data) (interface{}, error) {
+	if data.CS == nil {
+		return nil, nil
+	}
+
+	return data.CS, nil
+}
+
+// ArgValidator checks a parsed value, see ArgDef.Validators
+type ArgValidator func(data *Data, value interface{}) error
+
+// Validate runs the validators on value, user errors returned by the validators are wrapped in a ArgValidationError
+func (a *ArgDef) Validate(data *Data, value interface{}) error {
+	for _, v := range a.Validators {
+		err := v(data, value)
+		if err == nil {
+			continue
+		}
+
+		if IsUserError(err) {
+			return &ArgValidationError{ArgName: argName(a), Err: err}
+		}
+
+		return errors.WithMessage(err, "validating "+argName(a))
+	}
+
+	return nil
 }
 
 type ParsedArg struct {
@@ -102,6 +192,49 @@ func (p *ParsedArg) Bool() bool {
 	return false
 }
 
+func (p *ParsedArg) Float() float64 {
+	if p.Value == nil {
+		return 0
+	}
+
+	switch t := p.Value.(type) {
+	case float64:
+		return t
+	case float32:
+		return float64(t)
+	case int, int32, int64, uint, uint32, uint64:
+		return float64(p.Int64())
+	}
+
+	return 0
+}
+
+func (p *ParsedArg) Duration() time.Duration {
+	if p.Value == nil {
+		return 0
+	}
+
+	switch t := p.Value.(type) {
+	case time.Duration:
+		return t
+	}
+
+	return 0
+}
+
+func (p *ParsedArg) Channel() *dstate.ChannelState {
+	if p.Value == nil {
+		return nil
+	}
+
+	switch t := p.Value.(type) {
+	case *dstate.ChannelState:
+		return t
+	}
+
+	return nil
+}
+
 func (p *ParsedArg) MemberState() *dstate.MemberState {
 	if p.Value == nil {
 		return nil
@@ -123,6 +256,8 @@ func (p *ParsedArg) User() *discordgo.User {
 	}
 
 	switch t := p.Value.(type) {
+	case *discordgo.User:
+		return t
 	case *dstate.MemberState:
 		return t.DGoUser()
 	case *AdvUserMatch:
@@ -145,6 +280,67 @@ func (p *ParsedArg) AdvUser() *AdvUserMatch {
 	return nil
 }
 
+func (p *ParsedArg) Emoji() *EmojiMatch {
+	if p.Value == nil {
+		return nil
+	}
+
+	switch t := p.Value.(type) {
+	case *EmojiMatch:
+		return t
+	}
+
+	return nil
+}
+
+func (p *ParsedArg) Attachment() *discordgo.MessageAttachment {
+	if p.Value == nil {
+		return nil
+	}
+
+	switch t := p.Value.(type) {
+	case *discordgo.MessageAttachment:
+		return t
+	}
+
+	return nil
+}
+
+func (p *ParsedArg) MessageRef() *MessageRef {
+	if p.Value == nil {
+		return nil
+	}
+
+	switch t := p.Value.(type) {
+	case *MessageRef:
+		return t
+	}
+
+	return nil
+}
+
+// Values returns the individual values of a variadic arg as parsed args, so that the normal accessors can be used on them
+func (p *ParsedArg) Values() []*ParsedArg {
+	if p.Value == nil {
+		return nil
+	}
+
+	vals, ok := p.Value.([]interface{})
+	if !ok {
+		return []*ParsedArg{p}
+	}
+
+	out := make([]*ParsedArg, len(vals))
+	for i, v := range vals {
+		out[i] = &ParsedArg{
+			Def:   p.Def,
+			Value: v,
+		}
+	}
+
+	return out
+}
+
 // NewParsedArgs creates a new ParsedArg slice from defs passed, also filling default values
 func NewParsedArgs(defs []*ArgDef) []*ParsedArg {
 	out := make([]*ParsedArg, len(defs))
@@ -175,84 +371,453 @@ var (
 	// Create some convenience instances
 	Int             = &IntArg{}
 	Float           = &FloatArg{}
+	Bool            = &BoolArg{}
 	String          = &StringArg{}
+	Remainder       = &RemainderArg{}
 	User            = &UserArg{}
 	UserReqMention  = &UserArg{RequireMention: true}
 	UserID          = &UserIDArg{}
 	Channel         = &ChannelArg{}
+	Emoji           = &EmojiArg{}
+	Message         = &MessageArg{}
+	Attachment      = &AttachmentArg{}
+	Image           = &AttachmentArg{ContentTypes: []string{"image/*"}, AllowURL: true}
+	Duration        = &DurationArg{}
 	AdvUser         = &AdvUserArg{EnableUserID: true, EnableUsernameSearch: true, RequireMembership: true}
 	AdvUserNoMember = &AdvUserArg{EnableUserID: true, EnableUsernameSearch: true}
 )
 
 // IntArg matches and parses integer arguments
 // If min and max are not equal then the value has to be within min and max or else it will fail parsing
+// If HasMin or HasMax is set then only the bounds that are set are checked instead, allowing one sided bounds
 type IntArg struct {
-	Min, Max int64
+	Min, Max       int64
+	HasMin, HasMax bool
+
+	// AllowUnits enables unit suffixes such as "2.5k" (see NumberUnits) and thousands separators ("1,000")
+	AllowUnits bool
+	// AllowExpressions enables simple arithmetic using + - * / and parentheses, e.g "5*60"
+	AllowExpressions bool
+	// PercentOf returns what percentages (e.g "10%") are relative to, such as the balance of the user
+	// Percentages are not accepted if it's nil, and results of expressions with percentages are rounded down
+	PercentOf func(data *Data) (int64, error)
 }
 
 func (i *IntArg) Matches(def *ArgDef, part string) bool {
 	_, err := strconv.ParseInt(part, 10, 64)
-	return err == nil
+	if err == nil || !i.extended() {
+		return err == nil
+	}
+
+	// The real percentage base is only known when parsing
+	base := float64(1)
+	f, err := parseNumber(part, i.AllowUnits, i.AllowExpressions, i.percentBase(base))
+	if err != nil {
+		return false
+	}
+
+	_, ok := wholeNumber(f, strings.Contains(part, "%"))
+	return ok
 }
 func (i *IntArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
 	v, err := strconv.ParseInt(part, 10, 64)
 	if err != nil {
-		return nil, &InvalidInt{part}
+		if !i.extended() {
+			return nil, &InvalidInt{part}
+		}
+
+		v, err = i.parseExtended(part, data)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// A valid range has been specified
-	if i.Max != i.Min {
-		if i.Max < v || i.Min > v {
-			return nil, &OutOfRangeError{ArgName: def.Name, Got: v, Min: i.Min, Max: i.Max}
+	if !i.inRange(v) {
+		err := &OutOfRangeError{ArgName: argName(def), Got: v}
+		err.Min, err.Max = i.Min, i.Max
+		if i.HasMin && !i.HasMax {
+			err.Max = nil
+		} else if i.HasMax && !i.HasMin {
+			err.Min = nil
 		}
+		return nil, err
 	}
 
 	return v, nil
 }
 
+func (i *IntArg) extended() bool {
+	return i.AllowUnits || i.AllowExpressions || i.PercentOf != nil
+}
+
+func (i *IntArg) percentBase(base float64) *float64 {
+	if i.PercentOf == nil {
+		return nil
+	}
+
+	return &base
+}
+
+func (i *IntArg) parseExtended(part string, data *Data) (int64, error) {
+	var base int64
+	if i.PercentOf != nil && strings.Contains(part, "%") {
+		var err error
+		base, err = i.PercentOf(data)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := parseNumber(part, i.AllowUnits, i.AllowExpressions, i.percentBase(float64(base)))
+	if err != nil {
+		return 0, &InvalidInt{part}
+	}
+
+	v, ok := wholeNumber(f, strings.Contains(part, "%"))
+	if !ok {
+		return 0, &InvalidInt{part}
+	}
+
+	return v, nil
+}
+
+// wholeNumber returns f as a int64, ok is false if it's not a whole number or doesn't fit.
+// Percentages are rounded down instead, as they rarely result in whole numbers
+func wholeNumber(f float64, percent bool) (v int64, ok bool) {
+	rounded := math.Round(f)
+	if percent {
+		rounded = math.Floor(f + 1e-9)
+	} else if math.Abs(f-rounded) > 1e-9 {
+		return 0, false
+	}
+
+	if rounded >= math.MaxInt64 || rounded < math.MinInt64 {
+		return 0, false
+	}
+
+	return int64(rounded), true
+}
+
+func (i *IntArg) inRange(v int64) bool {
+	if i.HasMin || i.HasMax {
+		return (!i.HasMin || v >= i.Min) && (!i.HasMax || v <= i.Max)
+	}
+
+	// A valid range has been specified
+	if i.Max != i.Min {
+		return v >= i.Min && v <= i.Max
+	}
+
+	return true
+}
+
 func (i *IntArg) HelpName() string {
 	return "Whole number"
 }
 
+func (i *IntArg) ValueType() reflect.Type {
+	return reflect.TypeOf(int64(0))
+}
+
+func (i *IntArg) MatchScore(def *ArgDef, part string) int {
+	return ScoreNumber
+}
+
 // FloatArg matches and parses float arguments
 // If min and max are not equal then the value has to be within min and max or else it will fail parsing
+// If HasMin or HasMax is set then only the bounds that are set are checked instead, allowing one sided bounds
 type FloatArg struct {
-	Min, Max float64
+	Min, Max       float64
+	HasMin, HasMax bool
+
+	// AllowUnits enables unit suffixes such as "2.5k" (see NumberUnits), thousands separators ("1,000")
+	// and percentages, which are fractions ("10%" is 0.1) unless PercentOf is set
+	AllowUnits bool
+	// AllowExpressions enables simple arithmetic using + - * / and parentheses, e.g "5*60"
+	AllowExpressions bool
+	// PercentOf returns what percentages (e.g "10%") are relative to
+	PercentOf func(data *Data) (float64, error)
 }
 
 func (f *FloatArg) Matches(def *ArgDef, part string) bool {
 	_, err := strconv.ParseFloat(part, 64)
+	if err == nil || !f.extended() {
+		return err == nil
+	}
+
+	// The real percentage base is only known when parsing
+	base := float64(1)
+	_, err = parseNumber(part, f.AllowUnits, f.AllowExpressions, f.percentBase(base))
 	return err == nil
 }
 func (f *FloatArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
 	v, err := strconv.ParseFloat(part, 64)
 	if err != nil {
-		return nil, &InvalidFloat{part}
+		if !f.extended() {
+			return nil, &InvalidFloat{part}
+		}
+
+		base := float64(1)
+		if f.PercentOf != nil && strings.Contains(part, "%") {
+			base, err = f.PercentOf(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		v, err = parseNumber(part, f.AllowUnits, f.AllowExpressions, f.percentBase(base))
+		if err != nil {
+			return nil, &InvalidFloat{part}
+		}
 	}
 
-	// A valid range has been specified
-	if f.Max != f.Min {
-		if f.Max < v || f.Min > v {
-			return nil, &OutOfRangeError{ArgName: def.Name, Got: v, Min: f.Min, Max: f.Max, Float: true}
+	if !f.inRange(v) {
+		err := &OutOfRangeError{ArgName: argName(def), Got: v, Float: true}
+		err.Min, err.Max = f.Min, f.Max
+		if f.HasMin && !f.HasMax {
+			err.Max = nil
+		} else if f.HasMax && !f.HasMin {
+			err.Min = nil
 		}
+		return nil, err
 	}
 
 	return v, nil
 }
 
+func (f *FloatArg) extended() bool {
+	return f.AllowUnits || f.AllowExpressions || f.PercentOf != nil
+}
+
+func (f *FloatArg) percentBase(base float64) *float64 {
+	if f.PercentOf == nil && !f.AllowUnits {
+		return nil
+	}
+
+	return &base
+}
+
+func (f *FloatArg) inRange(v float64) bool {
+	if f.HasMin || f.HasMax {
+		return (!f.HasMin || v >= f.Min) && (!f.HasMax || v <= f.Max)
+	}
+
+	// A valid range has been specified
+	if f.Max != f.Min {
+		return v >= f.Min && v <= f.Max
+	}
+
+	return true
+}
+
 func (f *FloatArg) HelpName() string {
 	return "Decimal number"
 }
 
-// StringArg matches and parses float arguments
-type StringArg struct{}
+func (f *FloatArg) ValueType() reflect.Type {
+	return reflect.TypeOf(float64(0))
+}
+
+func (f *FloatArg) MatchScore(def *ArgDef, part string) int {
+	return ScoreNumber
+}
+
+var (
+	// DefaultBoolTrueWords and DefaultBoolFalseWords are used by BoolArg if no custom vocabulary is specified
+	DefaultBoolTrueWords  = []string{"yes", "true", "on", "enable", "1"}
+	DefaultBoolFalseWords = []string{"no", "false", "off", "disable", "0"}
+)
+
+// BoolArg matches and parses boolean arguments, such as "yes", "off" or "1"
+// If TrueWords or FalseWords are set then those are used instead of the default vocabulary, matching is case insensitive
+type BoolArg struct {
+	TrueWords  []string
+	FalseWords []string
+}
+
+func (b *BoolArg) Matches(def *ArgDef, part string) bool {
+	_, ok := b.parse(part)
+	return ok
+}
+
+func (b *BoolArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	v, ok := b.parse(part)
+	if !ok {
+		return nil, &InvalidBool{Part: part, TrueWords: b.trueWords(), FalseWords: b.falseWords()}
+	}
+
+	return v, nil
+}
+
+func (b *BoolArg) parse(part string) (val bool, ok bool) {
+	for _, v := range b.trueWords() {
+		if strings.EqualFold(v, part) {
+			return true, true
+		}
+	}
+
+	for _, v := range b.falseWords() {
+		if strings.EqualFold(v, part) {
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+func (b *BoolArg) trueWords() []string {
+	if len(b.TrueWords) > 0 {
+		return b.TrueWords
+	}
+
+	return DefaultBoolTrueWords
+}
+
+func (b *BoolArg) falseWords() []string {
+	if len(b.FalseWords) > 0 {
+		return b.FalseWords
+	}
+
+	return DefaultBoolFalseWords
+}
+
+func (b *BoolArg) HelpName() string {
+	return b.trueWords()[0] + "/" + b.falseWords()[0]
+}
+
+func (b *BoolArg) ValueType() reflect.Type {
+	return reflect.TypeOf(false)
+}
+
+// MassMentionMode decides what StringArg does with @everyone and @here mentions
+type MassMentionMode int
+
+const (
+	MassMentionsAllow MassMentionMode = iota
+	MassMentionsReject
+	MassMentionsEscape
+)
+
+// StringArg matches and parses text arguments
+// The zero value accepts anything, the other fields can be used to constrain it
+type StringArg struct {
+	// If non zero, the length in characters has to be within these
+	MinLen, MaxLen int
+
+	// If set, the text has to match this pattern
+	Pattern *regexp.Regexp
+	// Description of the pattern shown in help and errors, e.g "letters and numbers only"
+	PatternHelp string
+
+	MassMentions MassMentionMode
+
+	// Removes surrounding ``` code block fences (including the language tag)
+	TrimCodeBlock bool
+}
+
+func (s *StringArg) Matches(def *ArgDef, part string) bool {
+	if s.Pattern != nil {
+		return s.Pattern.MatchString(s.trim(part))
+	}
+
+	return true
+}
+
+func (s *StringArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	part = s.trim(part)
+
+	if s.MassMentions != MassMentionsAllow && (strings.Contains(part, "@everyone") || strings.Contains(part, "@here")) {
+		if s.MassMentions == MassMentionsReject {
+			return nil, &MassMentionNotAllowed{ArgName: argName(def)}
+		}
+
+		part = dutil.EscapeEveryoneMention(part)
+	}
+
+	if s.MinLen != 0 || s.MaxLen != 0 {
+		l := utf8.RuneCountInString(part)
+		if (s.MinLen != 0 && l < s.MinLen) || (s.MaxLen != 0 && l > s.MaxLen) {
+			return nil, &InvalidLength{ArgName: argName(def), Got: l, Min: s.MinLen, Max: s.MaxLen}
+		}
+	}
+
+	if s.Pattern != nil && !s.Pattern.MatchString(part) {
+		return nil, &PatternMismatch{ArgName: argName(def), Part: part, PatternHelp: s.PatternHelp}
+	}
+
+	return part, nil
+}
+
+func (s *StringArg) trim(part string) string {
+	if !s.TrimCodeBlock || !strings.HasPrefix(part, "```") || !strings.HasSuffix(part, "```") || len(part) < 6 {
+		return part
+	}
+
+	inner := part[3 : len(part)-3]
+
+	// Strip the language tag, it's the rest of the first line if it has no spaces
+	if i := strings.Index(inner, "\n"); i != -1 && !strings.ContainsAny(inner[:i], " \t") {
+		inner = inner[i+1:]
+	}
+
+	return strings.TrimSpace(inner)
+}
 
-func (s *StringArg) Matches(def *ArgDef, part string) bool                           { return true }
-func (s *StringArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) { return part, nil }
 func (s *StringArg) HelpName() string {
+	constraints := ""
+	switch {
+	case s.MinLen != 0 && s.MaxLen != 0:
+		constraints = fmt.Sprintf("%d-%d characters", s.MinLen, s.MaxLen)
+	case s.MaxLen != 0:
+		constraints = fmt.Sprintf("max %d characters", s.MaxLen)
+	case s.MinLen != 0:
+		constraints = fmt.Sprintf("min %d characters", s.MinLen)
+	}
+
+	if s.PatternHelp != "" {
+		if constraints != "" {
+			constraints += ", "
+		}
+		constraints += s.PatternHelp
+	}
+
+	if constraints != "" {
+		return "Text (" + constraints + ")"
+	}
+
 	return "Text"
 }
 
+func (s *StringArg) ValueType() reflect.Type {
+	return reflect.TypeOf("")
+}
+
+// argName returns the name of the def, or "Argument" if def is nil or it has no name
+func argName(def *ArgDef) string {
+	if def == nil || def.Name == "" {
+		return "Argument"
+	}
+
+	return def.Name
+}
+
+// RemainderArg takes the rest of the message, with the original spacing, newlines and containers kept intact.
+// It has to be the last arg, and any switches are still parsed out from the message.
+// Other arg types will not consume the rest of the message, extra args will instead cause a ErrTooManyArguments.
+type RemainderArg struct{}
+
+func (r *RemainderArg) Matches(def *ArgDef, part string) bool { return true }
+func (r *RemainderArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	return part, nil
+}
+func (r *RemainderArg) HelpName() string {
+	return "Text"
+}
+
+func (r *RemainderArg) ValueType() reflect.Type {
+	return reflect.TypeOf("")
+}
+
 // UserArg matches and parses user argument, optionally searching for the member if RequireMention is false
 type UserArg struct {
 	RequireMention bool
@@ -285,7 +850,7 @@ func (u *UserArg) Parse(def *ArgDef, part string, data *Data) (interface{}, erro
 		return nil, &ImproperMention{part}
 	} else if !u.RequireMention && data.GS != nil {
 		// Search for username
-		m, err := FindDiscordMemberByName(data.GS, part)
+		m, err := FindMemberByName(data.GS, part, searchChannelID(data))
 		if m != nil {
 			return m.DGoUser(), nil
 		}
@@ -302,66 +867,16 @@ func (u *UserArg) HelpName() string {
 	return "User"
 }
 
-func FindDiscordMemberByName(gs *dstate.GuildState, str string) (*dstate.MemberState, error) {
-	gs.RLock()
-	defer gs.RUnlock()
-
-	lowerIn := strings.ToLower(str)
-
-	partialMatches := make([]*dstate.MemberState, 0, 5)
-	fullMatches := make([]*dstate.MemberState, 0, 5)
-
-	for _, v := range gs.Members {
-		if v == nil {
-			continue
-		}
-
-		if v.Username == "" {
-			continue
-		}
-
-		if strings.EqualFold(str, v.Username) || strings.EqualFold(str, v.Nick) {
-			fullMatches = append(fullMatches, v.Copy())
-			if len(fullMatches) >= 5 {
-				break
-			}
-		} else if len(partialMatches) < 5 {
-			if strings.Contains(strings.ToLower(v.Username), lowerIn) {
-				partialMatches = append(partialMatches, v)
-			}
-		}
-	}
-
-	if len(fullMatches) == 1 {
-		return fullMatches[0].Copy(), nil
-	}
-
-	if len(fullMatches) == 0 && len(partialMatches) == 0 {
-		return nil, &UserNotFound{dutil.EscapeEveryoneMention(str)}
-	}
-
-	out := ""
-	for _, v := range fullMatches {
-		if out != "" {
-			out += ", "
-		}
-
-		out += "`" + v.Username + "`"
-	}
-
-	for _, v := range partialMatches {
-		if out != "" {
-			out += ", "
-		}
-
-		out += "`" + v.Username + "`"
-	}
+func (u *UserArg) ValueType() reflect.Type {
+	return reflect.TypeOf((*discordgo.User)(nil))
+}
 
-	if len(fullMatches) > 1 {
-		return nil, NewSimpleUserError("Too many users with that name, " + out + ". Please re-run the command with a narrower search, mention or ID.")
+func (u *UserArg) MatchScore(def *ArgDef, part string) int {
+	if strings.HasPrefix(part, "<@") && strings.HasSuffix(part, ">") {
+		return ScoreMention
 	}
 
-	return nil, NewSimpleUserError("Did you mean one of these? " + out + ". Please re-run the command with a narrower search, mention or ID")
+	return ScoreText
 }
 
 // UserIDArg matches a mention or a plain id, the user does not have to be a part of the server
@@ -412,9 +927,28 @@ func (u *UserIDArg) HelpName() string {
 	return "Mention/ID"
 }
 
-// UserIDArg matches a mention or a plain id, the user does not have to be a part of the server
-// The type of the ID is parsed into a int64
-type ChannelArg struct{}
+func (u *UserIDArg) ValueType() reflect.Type {
+	return reflect.TypeOf(int64(0))
+}
+
+var (
+	// Convenience channel type sets for ChannelArg.AllowedTypes
+	TextChannelTypes   = []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews}
+	VoiceChannelTypes  = []discordgo.ChannelType{discordgo.ChannelTypeGuildVoice}
+	ThreadChannelTypes = []discordgo.ChannelType{discordgo.ChannelTypeGuildNewsThread, discordgo.ChannelTypeGuildPublicThread, discordgo.ChannelTypeGuildPrivateThread}
+)
+
+// ChannelArg matches a mention or a plain id, optionally also searching by name, the parsed value is a *dstate.ChannelState
+type ChannelArg struct {
+	// Search for channels by name, with or without the leading #
+	EnableNameSearch bool
+
+	// If set, only channels of these types are accepted
+	AllowedTypes []discordgo.ChannelType
+
+	// Resolve IDs of channels outside the current server using the state
+	AllowOtherGuilds bool
+}
 
 func (ca *ChannelArg) Matches(def *ArgDef, part string) bool {
 	// Check for mention
@@ -428,14 +962,11 @@ func (ca *ChannelArg) Matches(def *ArgDef, part string) bool {
 		return true
 	}
 
-	return false
+	// name searches are enabled, any string can be used
+	return ca.EnableNameSearch
 }
 
 func (ca *ChannelArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
-	if data.GS == nil {
-		return nil, nil
-	}
-
 	var cID int64
 	if strings.HasPrefix(part, "<#") && len(part) > 3 {
 		// Direct mention
@@ -447,26 +978,278 @@ func (ca *ChannelArg) Parse(def *ArgDef, part string, data *Data) (interface{},
 		}
 
 		cID = parsed
-	} else {
-		id, err := strconv.ParseInt(part, 10, 64)
-		if err != nil {
-			return nil, &ImproperMention{part}
-		}
+	} else if id, err := strconv.ParseInt(part, 10, 64); err == nil {
 		cID = id
+	} else if ca.EnableNameSearch && data.GS != nil {
+		return FindChannelByName(data.GS, strings.TrimPrefix(part, "#"), ca.AllowedTypes)
+	} else {
+		return nil, &ImproperMention{part}
+	}
+
+	cs := ca.findChannel(data, cID)
+	if cs == nil {
+		return nil, &ChannelNotFound{cID}
+	}
+
+	if !channelTypeAllowed(cs.Type, ca.AllowedTypes) {
+		return nil, &InvalidChannelType{Channel: cs.Name, Got: cs.Type, Allowed: ca.AllowedTypes}
 	}
 
-	data.GS.RLock()
-	if c, ok := data.GS.Channels[cID]; ok {
+	return cs, nil
+}
+
+func (ca *ChannelArg) findChannel(data *Data, cID int64) *dstate.ChannelState {
+	if data.GS != nil {
+		data.GS.RLock()
+		c := data.GS.Channels[cID]
 		data.GS.RUnlock()
-		return c, nil
+		if c != nil {
+			return c
+		}
+	} else if data.CS != nil && data.CS.ID == cID {
+		return data.CS
 	}
-	data.GS.RUnlock()
 
-	return nil, &ImproperMention{part}
+	if ca.AllowOtherGuilds && data.System != nil && data.System.State != nil {
+		return data.System.State.Channel(true, cID)
+	}
+
+	return nil
 }
 
 func (ca *ChannelArg) HelpName() string {
-	return "Channel"
+	if len(ca.AllowedTypes) < 1 {
+		return "Channel"
+	}
+
+	return formatChannelTypes(ca.AllowedTypes) + " channel"
+}
+
+func (ca *ChannelArg) ValueType() reflect.Type {
+	return reflect.TypeOf((*dstate.ChannelState)(nil))
+}
+
+func (ca *ChannelArg) MatchScore(def *ArgDef, part string) int {
+	if strings.HasPrefix(part, "<#") && strings.HasSuffix(part, ">") {
+		return ScoreMention
+	}
+
+	if _, err := strconv.ParseInt(part, 10, 64); err == nil {
+		return ScoreID
+	}
+
+	return ScoreText
+}
+
+// FindChannelByName searches the guild for a channel with the provided name, if allowedTypes is non empty then only channels with those types are considered
+func FindChannelByName(gs *dstate.GuildState, str string, allowedTypes []discordgo.ChannelType) (*dstate.ChannelState, error) {
+	gs.RLock()
+	defer gs.RUnlock()
+
+	lowerIn := strings.ToLower(str)
+
+	partialMatches := make([]*dstate.ChannelState, 0, 5)
+	fullMatches := make([]*dstate.ChannelState, 0, 5)
+
+	for _, v := range gs.Channels {
+		if v == nil || !channelTypeAllowed(v.Type, allowedTypes) {
+			continue
+		}
+
+		if strings.EqualFold(str, v.Name) {
+			fullMatches = append(fullMatches, v)
+			if len(fullMatches) >= 5 {
+				break
+			}
+		} else if len(partialMatches) < 5 {
+			if strings.Contains(strings.ToLower(v.Name), lowerIn) {
+				partialMatches = append(partialMatches, v)
+			}
+		}
+	}
+
+	if len(fullMatches) == 1 {
+		return fullMatches[0], nil
+	}
+
+	if len(fullMatches) == 0 && len(partialMatches) == 0 {
+		return nil, &ChannelNameNotFound{dutil.EscapeEveryoneMention(str)}
+	}
+
+	out := ""
+	for _, v := range append(fullMatches, partialMatches...) {
+		if out != "" {
+			out += ", "
+		}
+
+		out += "<#" + discordgo.StrID(v.ID) + ">"
+	}
+
+	if len(fullMatches) > 1 {
+		return nil, NewSimpleUserError("Too many channels with that name, " + out + ". Please re-run the command with a mention or ID.")
+	}
+
+	return nil, NewSimpleUserError("Did you mean one of these? " + out + ". Please re-run the command with a mention or ID")
+}
+
+func channelTypeAllowed(t discordgo.ChannelType, allowed []discordgo.ChannelType) bool {
+	if len(allowed) < 1 {
+		return true
+	}
+
+	for _, v := range allowed {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func channelTypeName(t discordgo.ChannelType) string {
+	switch t {
+	case discordgo.ChannelTypeGuildText:
+		return "Text"
+	case discordgo.ChannelTypeGuildVoice:
+		return "Voice"
+	case discordgo.ChannelTypeGuildCategory:
+		return "Category"
+	case discordgo.ChannelTypeGuildNews:
+		return "News"
+	case discordgo.ChannelTypeGuildNewsThread, discordgo.ChannelTypeGuildPublicThread, discordgo.ChannelTypeGuildPrivateThread:
+		return "Thread"
+	case discordgo.ChannelTypeDM, discordgo.ChannelTypeGroupDM:
+		return "DM"
+	}
+
+	return "Unknown"
+}
+
+// formatChannelTypes returns the unique names of the types seperated by "/"
+func formatChannelTypes(types []discordgo.ChannelType) string {
+	out := ""
+	seen := make(map[string]bool)
+	for _, v := range types {
+		name := channelTypeName(v)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if out != "" {
+			out += "/"
+		}
+		out += name
+	}
+
+	return out
+}
+
+var messageLinkRegex = regexp.MustCompile(`^<?https://(?:(?:canary|ptb)\.)?discord(?:app)?\.com/channels/(\d+|@me)/(\d+)/(\d+)>?$`)
+
+// MessageRef is the result of a MessageArg
+type MessageRef struct {
+	// GuildID is 0 if the message is in a DM or only IDs were provided
+	GuildID   int64
+	ChannelID int64
+	MessageID int64
+
+	// Message is only set if MessageArg.Fetch is true
+	Message *discordgo.Message
+}
+
+// MessageArg matches and parses message links, "channelID-messageID" pairs and plain message ids (in the current channel)
+// returning a *MessageRef
+// Links to messages in other servers than the current one are not allowed
+type MessageArg struct {
+	// Fetch the message using the session, failing parsing if it could not be fetched
+	Fetch bool
+}
+
+func (ma *MessageArg) Matches(def *ArgDef, part string) bool {
+	_, ok := ma.parseRef(part)
+	return ok
+}
+
+func (ma *MessageArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	ref, ok := ma.parseRef(part)
+	if !ok {
+		return nil, &InvalidMessageRef{part}
+	}
+
+	if ref.ChannelID == 0 {
+		ref.ChannelID = data.Msg.ChannelID
+	}
+
+	if data.GS != nil {
+		if ref.GuildID != 0 && ref.GuildID != data.GS.ID {
+			return nil, NewSimpleUserError("That message is from another server")
+		}
+
+		data.GS.RLock()
+		_, ok := data.GS.Channels[ref.ChannelID]
+		data.GS.RUnlock()
+		if !ok {
+			return nil, &ChannelNotFound{ref.ChannelID}
+		}
+
+		ref.GuildID = data.GS.ID
+	} else if ref.GuildID != 0 {
+		return nil, NewSimpleUserError("That message is from a server, not this DM")
+	} else if ref.ChannelID != data.Msg.ChannelID {
+		return nil, &ChannelNotFound{ref.ChannelID}
+	}
+
+	if ma.Fetch {
+		msg, err := data.Session.ChannelMessage(ref.ChannelID, ref.MessageID)
+		if err != nil {
+			return nil, &MessageNotFound{ref.MessageID}
+		}
+		ref.Message = msg
+	}
+
+	return ref, nil
+}
+
+func (ma *MessageArg) parseRef(part string) (ref *MessageRef, ok bool) {
+	if m := messageLinkRegex.FindStringSubmatch(part); m != nil {
+		ref = &MessageRef{}
+		if m[1] != "@me" {
+			ref.GuildID, _ = strconv.ParseInt(m[1], 10, 64)
+		}
+		ref.ChannelID, _ = strconv.ParseInt(m[2], 10, 64)
+		ref.MessageID, _ = strconv.ParseInt(m[3], 10, 64)
+		return ref, true
+	}
+
+	if i := strings.Index(part, "-"); i != -1 {
+		channelID, err := strconv.ParseInt(part[:i], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		messageID, err := strconv.ParseInt(part[i+1:], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+
+		return &MessageRef{ChannelID: channelID, MessageID: messageID}, true
+	}
+
+	messageID, err := strconv.ParseInt(part, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	return &MessageRef{MessageID: messageID}, true
+}
+
+func (ma *MessageArg) HelpName() string {
+	return "Message link/ID"
+}
+
+func (ma *MessageArg) ValueType() reflect.Type {
+	return reflect.TypeOf((*MessageRef)(nil))
 }
 
 type AdvUserMatch struct {
@@ -539,7 +1322,7 @@ func (u *AdvUserArg) Parse(def *ArgDef, part string, data *Data) (interface{}, e
 	if u.EnableUsernameSearch && data.GS != nil && ms == nil && user == nil {
 		// Search for username
 		var err error
-		ms, err = FindDiscordMemberByName(data.GS, part)
+		ms, err = FindMemberByName(data.GS, part, searchChannelID(data))
 		if err != nil {
 			return nil, err
 		}
@@ -615,3 +1398,19 @@ func (u *AdvUserArg) HelpName() string {
 
 	return out
 }
+
+func (u *AdvUserArg) ValueType() reflect.Type {
+	return reflect.TypeOf((*AdvUserMatch)(nil))
+}
+
+func (u *AdvUserArg) MatchScore(def *ArgDef, part string) int {
+	if strings.HasPrefix(part, "<@") && strings.HasSuffix(part, ">") {
+		return ScoreMention
+	}
+
+	if _, err := strconv.ParseInt(part, 10, 64); err == nil && u.EnableUserID {
+		return ScoreID
+	}
+
+	return ScoreText
+}
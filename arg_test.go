@@ -1,8 +1,10 @@
 package dcmd
 
 import (
-	"github.com/bwmarrin/discordgo"
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
 	"github.com/stretchr/testify/assert"
+	"regexp"
 	"testing"
 )
 
@@ -32,6 +34,125 @@ func TestFloatArg(t *testing.T) {
 	assert.False(t, Float.Matches(nil, "1.2hello21"), "Should not match")
 }
 
+func TestIntArgExtended(t *testing.T) {
+	arg := &IntArg{AllowUnits: true, AllowExpressions: true, PercentOf: func(data *Data) (int64, error) { return 250, nil }}
+	def := &ArgDef{Name: "amount", Type: arg}
+
+	cases := []struct {
+		part     string
+		expected int64
+		err      bool
+	}{
+		{"1k", 1000, false},
+		{"2.5M", 2500000, false},
+		{"1,000", 1000, false},
+		{"1,000,000", 1000000, false},
+		{"5*60", 300, false},
+		{"(1+2)*3", 9, false},
+		{"-5", -5, false},
+		{"10%", 25, false},
+		{"33%", 82, false},
+		{"1.5", 0, true},
+		{"1/3", 0, true},
+		{"2.5k/1000", 0, true},
+		{"1,00", 0, true},
+		{"10,0000", 0, true},
+		{"5/0", 0, true},
+		{"5*", 0, true},
+		{"hello", 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.part, func(t *testing.T) {
+			v, err := arg.Parse(def, c.part, nil)
+			if c.err {
+				assert.Error(t, err)
+				assert.False(t, arg.Matches(def, c.part), "Should not match")
+				return
+			}
+
+			assert.True(t, arg.Matches(def, c.part), "Should match")
+			if assert.NoError(t, err) {
+				assert.Equal(t, c.expected, v)
+			}
+		})
+	}
+
+	assert.False(t, (&IntArg{AllowUnits: true}).Matches(def, "5*60"), "expressions should be disabled")
+	assert.False(t, (&IntArg{AllowUnits: true}).Matches(def, "10%"), "percentages should be disabled")
+	assert.False(t, Int.Matches(def, "1k"), "units should be disabled")
+}
+
+func TestNumberBounds(t *testing.T) {
+	def := &ArgDef{Name: "amount"}
+
+	_, err := (&IntArg{Min: 1, HasMin: true}).Parse(def, "1000000", nil)
+	assert.NoError(t, err, "no upper bound")
+
+	_, err = (&IntArg{Min: 1, HasMin: true}).Parse(def, "0", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "amount is too small (has to be at least 1)", err.Error())
+	}
+
+	_, err = (&IntArg{Max: 10, HasMax: true}).Parse(def, "-100", nil)
+	assert.NoError(t, err, "no lower bound")
+
+	_, err = (&IntArg{Min: 1, Max: 10}).Parse(def, "11", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "amount is too big (has to be within 1 - 10)", err.Error())
+	}
+
+	_, err = (&IntArg{Min: 1, Max: 10}).Parse(nil, "11", nil)
+	assert.Error(t, err, "Should not need a def")
+
+	_, err = (&FloatArg{Min: 1, Max: 10}).Parse(nil, "11", nil)
+	assert.Error(t, err, "Should not need a def")
+
+	v, err := (&FloatArg{AllowUnits: true, Max: 1, HasMax: true}).Parse(def, "50%", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0.5, v)
+	}
+
+	_, err = (&FloatArg{Max: 1, HasMax: true}).Parse(def, "1.5", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "amount is too big (has to be at most 1.000000)", err.Error())
+	}
+}
+
+func TestBoolArg(t *testing.T) {
+	cases := []struct {
+		part   string
+		match  bool
+		result bool
+	}{
+		{"yes", true, true},
+		{"On", true, true},
+		{"1", true, true},
+		{"disable", true, false},
+		{"FALSE", true, false},
+		{"maybe", false, false},
+	}
+
+	for _, c := range cases {
+		t.Run("case_"+c.part, func(t *testing.T) {
+			assert.Equal(t, c.match, Bool.Matches(nil, c.part), "Incorrect match")
+
+			v, err := Bool.Parse(nil, c.part, nil)
+			if !c.match {
+				assert.Error(t, err, "Should fail parsing")
+				return
+			}
+
+			assert.NoError(t, err, "Should parse sucessfully")
+			assert.Equal(t, c.result, v)
+		})
+	}
+
+	custom := &BoolArg{TrueWords: []string{"ja"}, FalseWords: []string{"nei"}}
+	assert.True(t, custom.Matches(nil, "ja"), "Should match")
+	assert.False(t, custom.Matches(nil, "yes"), "Should not match")
+}
+
 func TestUserIDArg(t *testing.T) {
 	d := &Data{
 		Msg: &discordgo.Message{
@@ -63,11 +184,11 @@ func TestUserIDArg(t *testing.T) {
 	}
 }
 
-func TestUserIDArg(t *testing.T) {
+func TestUserIDArgMentioned(t *testing.T) {
 	d := &Data{
 		Msg: &discordgo.Message{
 			Mentions: []*discordgo.User{
-				&discordgo.User{ID: "105487308693757952"},
+				&discordgo.User{ID: 105487308693757952},
 			},
 		},
 	}
@@ -85,13 +206,162 @@ func TestUserIDArg(t *testing.T) {
 	for _, c := range cases {
 		t.Run("case_"+c.part, func(t *testing.T) {
 			arg := &UserIDArg{}
-			matches := arg.Matches(c.part)
+			matches := arg.Matches(nil, c.part)
+			assert.Equal(t, c.match, matches, "Incorrect match")
+			if matches {
+				parsed, err := arg.Parse(nil, c.part, d)
+				assert.NoError(t, err, "Should parse sucessfully")
+				assert.Equal(t, c.result, parsed)
+			}
+		})
+	}
+}
+
+func TestEmojiArg(t *testing.T) {
+	cases := []struct {
+		part   string
+		match  bool
+		result *EmojiMatch
+	}{
+		{"<:dcmd:105487308693757952>", true, &EmojiMatch{ID: 105487308693757952, Name: "dcmd"}},
+		{"<a:dcmd:105487308693757952>", true, &EmojiMatch{ID: 105487308693757952, Name: "dcmd", Animated: true}},
+		{"😀", true, &EmojiMatch{Name: "😀"}},
+		{"👍🏽", true, &EmojiMatch{Name: "👍🏽"}},
+		{"👨‍👩‍👧", true, &EmojiMatch{Name: "👨‍👩‍👧"}},
+		{"🇳🇴", true, &EmojiMatch{Name: "🇳🇴"}},
+		{"1️⃣", true, &EmojiMatch{Name: "1️⃣"}},
+		{"😀😀", false, nil},
+		{"1", false, nil},
+		{"hello", false, nil},
+		{"<:dcmd:hello>", false, nil},
+	}
+
+	for _, c := range cases {
+		t.Run("case_"+c.part, func(t *testing.T) {
+			matches := Emoji.Matches(nil, c.part)
 			assert.Equal(t, c.match, matches, "Incorrect match")
 			if matches {
-				parsed, err := arg.Parse(c.part, d)
+				parsed, err := Emoji.Parse(nil, c.part, &Data{})
 				assert.NoError(t, err, "Should parse sucessfully")
 				assert.Equal(t, c.result, parsed)
 			}
 		})
 	}
 }
+
+func TestMessageArg(t *testing.T) {
+	d := &Data{
+		Msg: &discordgo.Message{
+			ChannelID: 1,
+		},
+	}
+
+	cases := []struct {
+		part   string
+		match  bool
+		result *MessageRef
+	}{
+		{"3", true, &MessageRef{ChannelID: 1, MessageID: 3}},
+		{"1-3", true, &MessageRef{ChannelID: 1, MessageID: 3}},
+		{"https://discord.com/channels/@me/1/3", true, &MessageRef{ChannelID: 1, MessageID: 3}},
+		{"https://canary.discordapp.com/channels/@me/1/3", true, &MessageRef{ChannelID: 1, MessageID: 3}},
+		{"hello", false, nil},
+		{"1-hello", false, nil},
+	}
+
+	for _, c := range cases {
+		t.Run("case_"+c.part, func(t *testing.T) {
+			matches := Message.Matches(nil, c.part)
+			assert.Equal(t, c.match, matches, "Incorrect match")
+			if matches {
+				parsed, err := Message.Parse(nil, c.part, d)
+				assert.NoError(t, err, "Should parse sucessfully")
+				assert.Equal(t, c.result, parsed)
+			}
+		})
+	}
+
+	_, err := Message.Parse(nil, "https://discord.com/channels/2/1/3", d)
+	assert.Error(t, err, "Should not allow messages from servers in dms")
+}
+
+func TestChannelArg(t *testing.T) {
+	gs := &dstate.GuildState{
+		Channels: map[int64]*dstate.ChannelState{
+			1: &dstate.ChannelState{ID: 1, Name: "general", Type: discordgo.ChannelTypeGuildText},
+			2: &dstate.ChannelState{ID: 2, Name: "General", Type: discordgo.ChannelTypeGuildVoice},
+			3: &dstate.ChannelState{ID: 3, Name: "logs", Type: discordgo.ChannelTypeGuildText},
+		},
+	}
+	d := &Data{GS: gs}
+
+	arg := &ChannelArg{EnableNameSearch: true, AllowedTypes: TextChannelTypes}
+	cases := []struct {
+		part     string
+		resultID int64
+	}{
+		{"<#1>", 1},
+		{"3", 3},
+		{"#general", 1},
+		{"logs", 3},
+		{"2", 0},
+		{"voice", 0},
+		{"4", 0},
+	}
+
+	for _, c := range cases {
+		t.Run("case_"+c.part, func(t *testing.T) {
+			assert.True(t, arg.Matches(nil, c.part), "Should match")
+			parsed, err := arg.Parse(nil, c.part, d)
+			if c.resultID == 0 {
+				assert.Error(t, err, "Should fail parsing")
+				return
+			}
+
+			assert.NoError(t, err, "Should parse sucessfully")
+			assert.Equal(t, c.resultID, parsed.(*dstate.ChannelState).ID)
+		})
+	}
+
+	_, err := Channel.Parse(nil, "general", d)
+	assert.Error(t, err, "Should not search by name")
+	_, err = (&ChannelArg{EnableNameSearch: true}).Parse(nil, "general", d)
+	assert.Error(t, err, "Should be ambiguous")
+}
+
+func TestStringArg(t *testing.T) {
+	cases := []struct {
+		name   string
+		arg    *StringArg
+		part   string
+		result string
+		ok     bool
+	}{
+		{"plain", String, "hello world", "hello world", true},
+		{"max len", &StringArg{MaxLen: 5}, "hello world", "", false},
+		{"min len", &StringArg{MinLen: 5}, "hey", "", false},
+		{"unicode len", &StringArg{MaxLen: 3}, "æøå", "æøå", true},
+		{"pattern", &StringArg{Pattern: regexp.MustCompile(`^[a-z]+$`)}, "hello", "hello", true},
+		{"pattern mismatch", &StringArg{Pattern: regexp.MustCompile(`^[a-z]+$`)}, "hello1", "", false},
+		{"reject everyone", &StringArg{MassMentions: MassMentionsReject}, "hi @everyone", "", false},
+		{"escape everyone", &StringArg{MassMentions: MassMentionsEscape}, "hi @here", "hi @\u200bhere", true},
+		{"code block", &StringArg{TrimCodeBlock: true}, "```go\nfmt.Println()\n```", "fmt.Println()", true},
+		{"code block no lang", &StringArg{TrimCodeBlock: true}, "```hello there```", "hello there", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v, err := c.arg.Parse(nil, c.part, nil)
+			if !c.ok {
+				assert.Error(t, err, "Should fail parsing")
+				assert.True(t, IsUserError(err), "Should be a user error")
+				return
+			}
+
+			assert.NoError(t, err, "Should parse sucessfully")
+			assert.Equal(t, c.result, v)
+		})
+	}
+
+	assert.Equal(t, "Text (2-32 characters, lowercase letters)", (&StringArg{MinLen: 2, MaxLen: 32, PatternHelp: "lowercase letters"}).HelpName())
+}
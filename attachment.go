@@ -0,0 +1,293 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"mime"
+	"net/url"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// AttachmentArg binds the attachments of the message to args, the parsed value is a *discordgo.MessageAttachment
+// Attachments are bound to the AttachmentArg defs in order, and are not taken from the text args (see ParseArgDefs)
+//
+// If AllowURL is set then a URL can be passed as a text arg instead when there is no attachment left for the arg,
+// the Filename and ContentType of the returned attachment is then guessed from the URL
+type AttachmentArg struct {
+	// ContentTypes are the allowed content types, either full ones like "image/png" or wildcards like "image/*"
+	// If empty then any type is allowed
+	ContentTypes []string
+
+	// MaxSize is the max size of the attachment in bytes, 0 for no limit
+	// This is not checked for URLs
+	MaxSize int
+
+	AllowURL bool
+}
+
+var _ ArgTypeScorer = (*AttachmentArg)(nil)
+
+// Matches returns true if part is a URL and URLs are allowed, the attachments of the message are not matched against text args
+func (a *AttachmentArg) Matches(def *ArgDef, part string) bool {
+	if !a.AllowURL {
+		return false
+	}
+
+	_, ok := parseAttachmentURL(part)
+	return ok
+}
+
+// Parse parses a URL passed instead of a attachment
+func (a *AttachmentArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	attachment, ok := parseAttachmentURL(part)
+	if !a.AllowURL || !ok {
+		return nil, &InvalidAttachment{Filename: part, Reason: "is not a valid URL"}
+	}
+
+	if len(a.ContentTypes) > 0 && !a.typeAllowed(attachment.ContentType) {
+		return nil, &InvalidAttachment{Filename: attachment.Filename, Reason: "has to be " + a.typesString()}
+	}
+
+	return attachment, nil
+}
+
+// ParseAttachment checks if the attachment satisfies the constraints
+func (a *AttachmentArg) ParseAttachment(def *ArgDef, attachment *discordgo.MessageAttachment, data *Data) (interface{}, error) {
+	if len(a.ContentTypes) > 0 && !a.typeAllowed(attachmentContentType(attachment)) {
+		return nil, &InvalidAttachment{Filename: attachment.Filename, Reason: "has to be " + a.typesString()}
+	}
+
+	if a.MaxSize > 0 && attachment.Size > a.MaxSize {
+		return nil, &InvalidAttachment{Filename: attachment.Filename, Reason: "is too big (max " + formatBytes(a.MaxSize) + ")"}
+	}
+
+	return attachment, nil
+}
+
+func (a *AttachmentArg) HelpName() string {
+	return "Attachment"
+}
+
+func (a *AttachmentArg) ValueType() reflect.Type {
+	return reflect.TypeOf((*discordgo.MessageAttachment)(nil))
+}
+
+func (a *AttachmentArg) MatchScore(def *ArgDef, part string) int {
+	return ScoreNumber
+}
+
+func (a *AttachmentArg) typeAllowed(contentType string) bool {
+	// Strip parameters such as "; charset=utf-8"
+	if i := strings.IndexByte(contentType, ';'); i != -1 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(strings.ToLower(contentType))
+	if contentType == "" {
+		return false
+	}
+
+	for _, v := range a.ContentTypes {
+		v = strings.ToLower(v)
+		if v == contentType {
+			return true
+		}
+
+		if strings.HasSuffix(v, "/*") && strings.HasPrefix(contentType, v[:len(v)-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typesString returns the allowed types in a human readable form, e.g "image or video"
+func (a *AttachmentArg) typesString() string {
+	names := make([]string, len(a.ContentTypes))
+	for i, v := range a.ContentTypes {
+		names[i] = strings.TrimSuffix(v, "/*")
+	}
+
+	return strings.Join(names, " or ")
+}
+
+// attachmentContentType returns the content type of the attachment, guessing it from the filename if it's not set
+func attachmentContentType(attachment *discordgo.MessageAttachment) string {
+	if attachment.ContentType != "" {
+		return attachment.ContentType
+	}
+
+	return mime.TypeByExtension(strings.ToLower(path.Ext(attachment.Filename)))
+}
+
+// parseAttachmentURL returns a attachment for a http(s) URL
+func parseAttachmentURL(part string) (*discordgo.MessageAttachment, bool) {
+	if !strings.HasPrefix(part, "http://") && !strings.HasPrefix(part, "https://") {
+		return nil, false
+	}
+
+	parsed, err := url.Parse(part)
+	if err != nil || parsed.Host == "" {
+		return nil, false
+	}
+
+	filename := path.Base(parsed.Path)
+	if filename == "/" || filename == "." {
+		filename = ""
+	}
+
+	return &discordgo.MessageAttachment{
+		URL:         part,
+		Filename:    filename,
+		ContentType: mime.TypeByExtension(strings.ToLower(path.Ext(filename))),
+	}, true
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return strconv.Itoa(n>>20) + "MB"
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return strconv.Itoa(n>>10) + "KB"
+	}
+
+	return strconv.Itoa(n) + " bytes"
+}
+
+// textArgDefs are the defs that are left to be matched against the text args after binding the attachments
+type textArgDefs struct {
+	defs     []*ArgDef
+	required int
+	combos   [][]int
+
+	// indexes[i] is the index of defs[i] in the original defs
+	indexes []int
+}
+
+// bindAttachments binds the attachments of the message to the AttachmentArg defs, and returns the defs left for the text args.
+// AttachmentArg defs without a attachment are only left for the text args if they accept URLs.
+func bindAttachments(defs []*ArgDef, required int, combos [][]int, data *Data, parsedArgs []*ParsedArg, provided []bool) (*textArgDefs, error) {
+	text := &textArgDefs{defs: defs, required: required, combos: combos, indexes: make([]int, len(defs))}
+	for i := range defs {
+		text.indexes[i] = i
+	}
+
+	hasAttachmentDefs := false
+	for _, def := range defs {
+		if _, ok := def.Type.(*AttachmentArg); ok {
+			hasAttachmentDefs = true
+			break
+		}
+	}
+
+	if !hasAttachmentDefs {
+		return text, nil
+	}
+
+	var attachments []*discordgo.MessageAttachment
+	if data.Msg != nil {
+		attachments = data.Msg.Attachments
+	}
+
+	// Maps the index in defs to the index in text.defs, -1 if it was bound to attachments
+	textIndex := make([]int, len(defs))
+	text.defs = make([]*ArgDef, 0, len(defs))
+	text.indexes = text.indexes[:0]
+	text.required = 0
+
+	for i, def := range defs {
+		t, ok := def.Type.(*AttachmentArg)
+		if ok && len(attachments) > 0 {
+			n := 1
+			if def.Variadic {
+				// Leave a attachment for each of the required attachment args after this one, like variadicEnd does for text args
+				n = len(attachments) - requiredAttachmentsAfter(defs, required, i)
+				if n < 1 {
+					n = 1
+				}
+			}
+
+			vals := make([]interface{}, 0, n)
+			for _, attachment := range attachments[:n] {
+				val, err := t.ParseAttachment(def, attachment, data)
+				if err == nil {
+					err = def.Validate(data, val)
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				vals = append(vals, val)
+			}
+			attachments = attachments[n:]
+
+			if def.Variadic {
+				parsedArgs[i].Value = vals
+			} else {
+				parsedArgs[i].Value = vals[0]
+			}
+			provided[i] = true
+			textIndex[i] = -1
+			continue
+		}
+
+		if ok && !t.AllowURL {
+			if i < required && len(combos) < 1 {
+				return nil, &MissingAttachment{ArgName: argName(def)}
+			}
+
+			textIndex[i] = -1
+			continue
+		}
+
+		textIndex[i] = len(text.defs)
+		text.defs = append(text.defs, def)
+		text.indexes = append(text.indexes, i)
+		if i < required {
+			text.required++
+		}
+	}
+
+	if len(combos) < 1 {
+		return text, nil
+	}
+
+	// Remap the combos to the text defs, leaving out the ones with attachment args we don't have attachments for
+	text.combos = make([][]int, 0, len(combos))
+	var missing *ArgDef
+	for _, combo := range combos {
+		textCombo := make([]int, 0, len(combo))
+		for _, v := range combo {
+			if textIndex[v] != -1 {
+				textCombo = append(textCombo, textIndex[v])
+			} else if !provided[v] {
+				missing = defs[v]
+				textCombo = nil
+				break
+			}
+		}
+
+		if textCombo != nil {
+			text.combos = append(text.combos, textCombo)
+		}
+	}
+
+	if len(text.combos) < 1 {
+		return nil, &MissingAttachment{ArgName: argName(missing)}
+	}
+
+	return text, nil
+}
+
+// requiredAttachmentsAfter returns the number of required AttachmentArg defs after defs[i]
+func requiredAttachmentsAfter(defs []*ArgDef, required int, i int) int {
+	n := 0
+	for j := i + 1; j < required && j < len(defs); j++ {
+		if _, ok := defs[j].Type.(*AttachmentArg); ok {
+			n++
+		}
+	}
+
+	return n
+}
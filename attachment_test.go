@@ -0,0 +1,90 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAttachmentArgs(t *testing.T) {
+	defs := []*ArgDef{
+		{Name: "image", Type: &AttachmentArg{ContentTypes: []string{"image/*"}, MaxSize: 1 << 20}},
+		{Name: "caption", Type: Remainder},
+	}
+
+	png := &discordgo.MessageAttachment{Filename: "cat.png", Size: 100}
+	data := &Data{MsgStrippedPrefix: "a cat", Msg: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{png}}}
+	err := ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, png, data.Args[0].Attachment())
+		assert.Equal(t, "a cat", data.Args[1].Str(), "the attachment should not take up a text arg")
+	}
+
+	data = &Data{MsgStrippedPrefix: "a cat", Msg: &discordgo.Message{}}
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	assert.IsType(t, &MissingAttachment{}, err)
+
+	txt := &discordgo.MessageAttachment{Filename: "cat.txt", ContentType: "text/plain; charset=utf-8"}
+	data = &Data{MsgStrippedPrefix: "a cat", Msg: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{txt}}}
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.Error(t, err) {
+		assert.Equal(t, `"cat.txt" has to be image`, err.Error())
+	}
+
+	big := &discordgo.MessageAttachment{Filename: "cat.jpg", Size: 2 << 20}
+	data = &Data{MsgStrippedPrefix: "a cat", Msg: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{big}}}
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.Error(t, err) {
+		assert.Equal(t, `"cat.jpg" is too big (max 1MB)`, err.Error())
+	}
+}
+
+func TestAttachmentArgVariadic(t *testing.T) {
+	defs := []*ArgDef{
+		{Name: "images", Type: Attachment, Variadic: true},
+		{Name: "thumbnail", Type: Attachment},
+		{Name: "extra", Type: Attachment},
+	}
+
+	a := &discordgo.MessageAttachment{Filename: "a.png"}
+	b := &discordgo.MessageAttachment{Filename: "b.png"}
+	c := &discordgo.MessageAttachment{Filename: "c.png"}
+	data := &Data{Msg: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{a, b, c}}}
+	err := ParseArgDefs(defs, 2, nil, data, nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []interface{}{a, b}, data.Args[0].Value, "should leave one for the required thumbnail")
+		assert.Equal(t, c, data.Args[1].Attachment())
+		assert.Nil(t, data.Args[2].Value)
+	}
+
+	data = &Data{Msg: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{a}}}
+	err = ParseArgDefs(defs, 2, nil, data, nil)
+	assert.IsType(t, &MissingAttachment{}, err)
+}
+
+func TestAttachmentArgURL(t *testing.T) {
+	defs := []*ArgDef{{Name: "image", Type: Image}, {Name: "caption", Type: Remainder}}
+
+	data := &Data{MsgStrippedPrefix: "https://example.com/cat.png a cat", Msg: &discordgo.Message{}}
+	err := ParseArgDefs(defs, 1, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "https://example.com/cat.png", data.Args[0].Attachment().URL)
+		assert.Equal(t, "cat.png", data.Args[0].Attachment().Filename)
+		assert.Equal(t, "a cat", data.Args[1].Str())
+	}
+
+	png := &discordgo.MessageAttachment{Filename: "cat.png", ContentType: "image/png"}
+	data = &Data{MsgStrippedPrefix: "https://example.com", Msg: &discordgo.Message{Attachments: []*discordgo.MessageAttachment{png}}}
+	err = ParseArgDefs(defs, 1, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, png, data.Args[0].Attachment(), "should prefer the attachment")
+		assert.Equal(t, "https://example.com", data.Args[1].Str())
+	}
+
+	assert.False(t, Image.Matches(nil, "hello"))
+	assert.False(t, Attachment.Matches(nil, "https://example.com/cat.png"), "URLs should not be allowed")
+}
+
+func TestAttachmentArgHelp(t *testing.T) {
+	assert.Equal(t, "<image:Attachment> [caption:Text]", (&StdHelpFormatter{}).ArgDefLine([]*ArgDef{{Name: "image", Type: Image}, {Name: "caption", Type: Remainder}}, 1))
+}
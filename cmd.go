@@ -3,15 +3,21 @@ package dcmd
 import (
 	"reflect"
 	"strings"
+	"sync/atomic"
 )
 
 // RegisteredCommand represents a registered command to the system.
 // RegisteredCommand.Cmd may exist in other RegisteredCommands but the RegisteredCommand wrapper itself
 // is unique per route
+//
+// The middleware chain of the command is built on its first invocation and cached. It's rebuilt when the middlewares
+// of the containers it was built from or of its trigger change, or when it's ran through a different chain of containers.
 type RegisteredCommand struct {
-	Command                  Cmd
-	Trigger                  *Trigger
-	builtFullMiddlewareChain RunFunc
+	Command Cmd
+	Trigger *Trigger
+
+	// The cached *builtChain, see middlewareChain
+	builtChain atomic.Value
 }
 
 // FormatNames returns a string with names and if includedAliases is true, aliases seperated by seperator
@@ -0,0 +1,304 @@
+package dcmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Specificity scores used when matching combos, a higher score means the part is more likely meant for that arg
+const (
+	ScoreText    = 1
+	ScoreNumber  = 2 // also used for other typed values, such as booleans and emojis
+	ScoreID      = 3
+	ScoreMention = 4
+)
+
+// ArgTypeScorer can optionally be implemented by arg types to score how specific a match is,
+// arg types not implementing it are scored by the shape of the part (see ScorePart)
+type ArgTypeScorer interface {
+	MatchScore(def *ArgDef, part string) int
+}
+
+var mentionRegex = regexp.MustCompile(`^<(@!?|@&|#)\d+>$`)
+
+// MatchScore returns the specificity score of part for def, def.Type.Matches should return true for part
+func MatchScore(def *ArgDef, part string) int {
+	switch t := def.Type.(type) {
+	case ArgTypeScorer:
+		return t.MatchScore(def, part)
+	case *StringArg, *RemainderArg:
+		return ScoreText
+	}
+
+	score := ScorePart(part)
+	if score < ScoreNumber {
+		// it's still more specific than plain text
+		return ScoreNumber
+	}
+
+	return score
+}
+
+// ScorePart scores the part by its shape alone
+func ScorePart(part string) int {
+	if mentionRegex.MatchString(part) {
+		return ScoreMention
+	}
+
+	if len(part) >= 15 {
+		if _, err := strconv.ParseInt(part, 10, 64); err == nil {
+			return ScoreID
+		}
+	}
+
+	if _, err := strconv.ParseFloat(part, 64); err == nil {
+		return ScoreNumber
+	}
+
+	return ScoreText
+}
+
+// ComboMatch is a candidate combo considered by MatchCombos
+type ComboMatch struct {
+	Combo []int
+
+	// Spans[i] is the start and end (exclusive) index in the args of Combo[i]
+	Spans [][2]int
+
+	// The total specificity score of all the parts
+	Score int
+
+	// Set if this candidate was rejected, if it was only rejected because of args left over then Spans and Score
+	// are of the best match of the args before those
+	RejectReason string
+}
+
+func (c *ComboMatch) String() string {
+	if c.RejectReason != "" {
+		return fmt.Sprintf("%v: rejected, %s", c.Combo, c.RejectReason)
+	}
+
+	return fmt.Sprintf("%v: score %d, spans %v", c.Combo, c.Score, c.Spans)
+}
+
+// MatchCombos finds the best combo for the args, returning nil if no combo matched, and also returns all the candidates
+// that were considered (including the rejected ones with the reason) for debugging.
+//
+// A candidate has to consume all the args, Variadic args can end at any matching part and RemainderArg's consume the rest,
+// the possible ends are backtracked over to find the best match.
+// The candidate with the highest total score wins, with ties going to the first one.
+//
+// If combos is empty, candidates are made from the first required defs followed by any of the optional defs in order,
+// allowing optional args in the middle to be skipped. These are built once for each number of defs and required defs.
+// The Combo of the candidates is a copy, so it can be modified.
+func MatchCombos(defs []*ArgDef, required int, combos [][]int, args []*RawArg) (best *ComboMatch, candidates []*ComboMatch) {
+	if len(combos) < 1 {
+		combos = optionalCombos(len(defs), required)
+	}
+
+	candidates = make([]*ComboMatch, 0, len(combos))
+	for _, combo := range combos {
+		candidate := &ComboMatch{Combo: append([]int(nil), combo...)}
+		candidates = append(candidates, candidate)
+
+		m := &comboMatcher{defs: defs, combo: combo, args: args, memo: make([]matchResult, (len(combo)+1)*(len(args)+1))}
+		result := m.match(0, 0)
+		if !result.ok {
+			candidate.RejectReason = m.reason
+
+			// Check if it only failed because of args left over, so that can be reported instead
+			m.allowLeftOver = true
+			m.memo = make([]matchResult, len(m.memo))
+			if result = m.match(0, 0); result.ok {
+				candidate.Spans = m.spans()
+				candidate.Score = result.score
+			}
+			continue
+		}
+
+		candidate.Spans = m.spans()
+		candidate.Score = result.score
+		if best == nil || candidate.Score > best.Score {
+			best = candidate
+		}
+	}
+
+	return
+}
+
+// maxOptionalSkip is the maximum number of optional defs we generate skipping combos for, as it grows exponentially
+const maxOptionalSkip = 8
+
+// builtOptionalCombos caches the combos made by optionalCombos, they only depend on the number of defs and required defs
+var builtOptionalCombos sync.Map
+
+// optionalCombos returns the combos of the required defs followed by all subsets of the optional ones,
+// ordered so that earlier optional defs are preferred.
+// The combos are cached and shared, so they must not be modified
+func optionalCombos(numDefs, required int) [][]int {
+	if required > numDefs {
+		required = numDefs
+	}
+
+	key := [2]int{numDefs, required}
+	if cached, ok := builtOptionalCombos.Load(key); ok {
+		return cached.([][]int)
+	}
+
+	combos := buildOptionalCombos(numDefs, required)
+	builtOptionalCombos.Store(key, combos)
+	return combos
+}
+
+func buildOptionalCombos(numDefs, required int) [][]int {
+
+	optional := numDefs - required
+	if optional > maxOptionalSkip {
+		all := make([]int, numDefs)
+		for k := range all {
+			all[k] = k
+		}
+		return [][]int{all}
+	}
+
+	out := make([][]int, 0, 1<<uint(optional))
+	for mask := (1 << uint(optional)) - 1; mask >= 0; mask-- {
+		combo := make([]int, 0, numDefs)
+		for i := 0; i < required; i++ {
+			combo = append(combo, i)
+		}
+
+		for i := 0; i < optional; i++ {
+			if mask&(1<<uint(optional-1-i)) != 0 {
+				combo = append(combo, required+i)
+			}
+		}
+
+		out = append(out, combo)
+	}
+
+	return out
+}
+
+type comboMatcher struct {
+	defs  []*ArgDef
+	combo []int
+	args  []*RawArg
+
+	// memo[ci*(len(args)+1)+pos] is the result of match(ci, pos), so that each is only matched once
+	// instead of once for every way the variadic args before it can end
+	memo []matchResult
+
+	// Set to match even if there's args left over
+	allowLeftOver bool
+
+	// The reason the last attempt failed
+	reason string
+}
+
+type matchResult struct {
+	done bool
+	ok   bool
+
+	// The best total score of combo[ci:], and the end of the span of combo[ci] in that match
+	score int
+	end   int
+}
+
+// match returns the best match of combo[ci:] to args[pos:], trying all the possible ends of variadic args.
+// Ties go to the match where the variadic args are the longest
+func (m *comboMatcher) match(ci, pos int) matchResult {
+	key := ci*(len(m.args)+1) + pos
+	if m.memo[key].done {
+		return m.memo[key]
+	}
+
+	result := m.matchUncached(ci, pos)
+	result.done = true
+	m.memo[key] = result
+	return result
+}
+
+func (m *comboMatcher) matchUncached(ci, pos int) matchResult {
+	if ci >= len(m.combo) {
+		if pos < len(m.args) && !m.allowLeftOver {
+			m.reason = fmt.Sprintf("%d arg(s) left over", len(m.args)-pos)
+			return matchResult{}
+		}
+
+		return matchResult{ok: true}
+	}
+
+	def := m.defs[m.combo[ci]]
+	if pos >= len(m.args) {
+		m.reason = "not enough args for " + argName(def)
+		return matchResult{}
+	}
+
+	if !def.Type.Matches(def, m.args[pos].Str) {
+		m.reason = fmt.Sprintf("%s (%s) does not match %q", argName(def), def.Type.HelpName(), m.args[pos].Str)
+		return matchResult{}
+	}
+	score := MatchScore(def, m.args[pos].Str)
+
+	if _, ok := def.Type.(*RemainderArg); ok {
+		return m.then(ci, len(m.args), score)
+	}
+
+	if !def.Variadic {
+		return m.then(ci, pos+1, score)
+	}
+
+	// Find all the possible ends of the variadic arg, then try them from the longest to the shortest
+	ends := []int{pos + 1}
+	scores := []int{score}
+	for end := pos + 1; end < len(m.args) && def.Type.Matches(def, m.args[end].Str); end++ {
+		scores = append(scores, scores[len(scores)-1]+MatchScore(def, m.args[end].Str))
+		ends = append(ends, end+1)
+	}
+
+	var best matchResult
+	for i := len(ends) - 1; i >= 0; i-- {
+		if result := m.then(ci, ends[i], scores[i]); result.ok && (!best.ok || result.score > best.score) {
+			best = result
+		}
+	}
+
+	return best
+}
+
+// then returns the match of combo[ci] ending at end with the score, followed by the best match of the rest
+func (m *comboMatcher) then(ci, end, score int) matchResult {
+	rest := m.match(ci+1, end)
+	if !rest.ok {
+		return matchResult{}
+	}
+
+	return matchResult{ok: true, score: score + rest.score, end: end}
+}
+
+// spans returns the spans of the best match, match(0, 0) has to be ok
+func (m *comboMatcher) spans() [][2]int {
+	spans := make([][2]int, len(m.combo))
+	pos := 0
+	for ci := range m.combo {
+		end := m.match(ci, pos).end
+		spans[ci] = [2]int{pos, end}
+		pos = end
+	}
+
+	return spans
+}
+
+// FormatCandidates returns a human readable list of the candidates, useful for debugging combos
+func FormatCandidates(candidates []*ComboMatch) string {
+	lines := make([]string, len(candidates))
+	for i, v := range candidates {
+		lines[i] = v.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
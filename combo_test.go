@@ -0,0 +1,120 @@
+package dcmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMatchCombos(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		defs     []*ArgDef
+		required int
+		combos   [][]int
+		combo    []int
+		spans    [][2]int
+	}{
+		{"mention over text", "<@105487308693757952>", []*ArgDef{{Type: String}, {Type: UserReqMention}}, 0, [][]int{{0}, {1}}, []int{1}, [][2]int{{0, 1}}},
+		{"number over text", "10", []*ArgDef{{Type: String}, {Type: Int}}, 0, [][]int{{0}, {1}}, []int{1}, [][2]int{{0, 1}}},
+		{"text", "hello", []*ArgDef{{Type: Int}, {Type: String}}, 0, [][]int{{0}, {1}}, []int{1}, [][2]int{{0, 1}}},
+		{"any order", "<@105487308693757952> 10", []*ArgDef{{Type: Int}, {Type: UserReqMention}}, 0, [][]int{{0, 1}, {1, 0}}, []int{1, 0}, [][2]int{{0, 1}, {1, 2}}},
+		{"skip optional", "<@105487308693757952> spam here", []*ArgDef{{Type: UserReqMention}, {Type: Int}, {Type: Remainder}}, 1, nil, []int{0, 2}, [][2]int{{0, 1}, {1, 3}}},
+		{"keep optional", "<@105487308693757952> 7 spam here", []*ArgDef{{Type: UserReqMention}, {Type: Int}, {Type: Remainder}}, 1, nil, []int{0, 1, 2}, [][2]int{{0, 1}, {1, 2}, {2, 4}}},
+		{"variadic backtracking", "1 2 3", []*ArgDef{{Type: Int, Variadic: true}, {Type: Int}}, 2, nil, []int{0, 1}, [][2]int{{0, 2}, {2, 3}}},
+		{"variadic mentions", "<@1> <@2> reason", []*ArgDef{{Type: UserReqMention, Variadic: true}, {Type: Remainder}}, 2, nil, []int{0, 1}, [][2]int{{0, 2}, {2, 3}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			best, candidates := MatchCombos(c.defs, c.required, c.combos, SplitArgs(c.input))
+			if !assert.NotNil(t, best, "Should find a match") {
+				t.Log(FormatCandidates(candidates))
+				return
+			}
+
+			assert.Equal(t, c.combo, best.Combo)
+			assert.Equal(t, c.spans, best.Spans)
+		})
+	}
+}
+
+func TestMatchCombosRejected(t *testing.T) {
+	defs := []*ArgDef{{Name: "limit", Type: Int}, {Name: "user", Type: UserReqMention}}
+	best, candidates := MatchCombos(defs, 0, [][]int{{0}, {0, 1}}, SplitArgs("hello"))
+	assert.Nil(t, best, "Should not find a match")
+	if assert.Len(t, candidates, 2) {
+		assert.Equal(t, `limit (Whole number) does not match "hello"`, candidates[0].RejectReason)
+		assert.Equal(t, `limit (Whole number) does not match "hello"`, candidates[1].RejectReason)
+	}
+
+	_, candidates = MatchCombos(defs, 0, [][]int{{0}}, SplitArgs("1 2"))
+	assert.Equal(t, "1 arg(s) left over", candidates[0].RejectReason)
+}
+
+func TestNoComboFoundCandidates(t *testing.T) {
+	defs := []*ArgDef{{Name: "limit", Type: Int}, {Name: "user", Type: UserReqMention}}
+	err := ParseArgDefs(defs, 0, [][]int{{0}, {0, 1}}, &Data{MsgStrippedPrefix: "hello"}, SplitArgs("hello"))
+	assert.Equal(t, ErrNoComboFound, errors.Cause(err))
+	assert.True(t, IsUserError(err), "Should be a user error")
+	if nc, ok := err.(*NoComboFound); assert.True(t, ok, "Should be a NoComboFound") {
+		assert.Len(t, nc.Candidates, 2)
+	}
+
+	// Args left over are reported as too many arguments
+	err = ParseArgDefs(defs, 0, [][]int{{0}, {0, 1}}, &Data{MsgStrippedPrefix: "10 <@1> 20"}, SplitArgs("10 <@1> 20"))
+	assert.Equal(t, ErrTooManyArguments, errors.Cause(err))
+	if pe, ok := err.(*ParseError); assert.True(t, ok, "Should be a ParseError") {
+		assert.Equal(t, "20", pe.Part)
+		assert.Len(t, pe.Candidates, 2)
+		_, marker, ok := pe.Marker()
+		assert.True(t, ok, "Should have a marker")
+		assert.Equal(t, "        ^^", marker)
+	}
+
+	// Without combos the error for the first def is shown, along with the generated candidates
+	err = ParseArgDefs(defs, 1, nil, &Data{MsgStrippedPrefix: "hello"}, SplitArgs("hello"))
+	if pe, ok := err.(*ParseError); assert.True(t, ok, "Should be a ParseError") {
+		assert.Equal(t, "limit", pe.ArgName)
+		assert.Len(t, pe.Candidates, 2)
+	}
+}
+
+func TestOptionalCombosCached(t *testing.T) {
+	a := optionalCombos(4, 1)
+	b := optionalCombos(4, 1)
+	assert.Len(t, a, 8)
+	assert.True(t, &a[0][0] == &b[0][0], "Should reuse the built combos")
+	assert.Equal(t, []int{0, 1, 2, 3}, a[0])
+	assert.Equal(t, []int{0}, a[7])
+}
+
+type countingArgType struct {
+	IntArg
+	calls int
+}
+
+func (c *countingArgType) Matches(def *ArgDef, part string) bool {
+	c.calls++
+	return c.IntArg.Matches(def, part)
+}
+
+func TestMatchCombosVariadicBounded(t *testing.T) {
+	typ := &countingArgType{}
+	defs := []*ArgDef{{Type: typ, Variadic: true}, {Type: typ, Variadic: true}, {Type: typ, Variadic: true}, {Type: typ}}
+
+	input := strings.Repeat("1 ", 60) + "x"
+	best, _ := MatchCombos(defs, 4, [][]int{{0, 1, 2, 3}}, SplitArgs(input))
+	assert.Nil(t, best, "Should not find a match")
+	assert.True(t, typ.calls < 20000, "Should not try every way the variadic args can end, calls: %d", typ.calls)
+}
+
+func TestMatchCombosCopied(t *testing.T) {
+	defs := []*ArgDef{{Type: Int}, {Type: Int}}
+	_, candidates := MatchCombos(defs, 1, nil, SplitArgs("1 2"))
+	candidates[0].Combo[0] = 5
+
+	assert.Equal(t, []int{0, 1}, optionalCombos(2, 1)[0], "Should not modify the cached combos")
+}
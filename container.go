@@ -2,6 +2,8 @@ package dcmd
 
 import (
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type MiddleWareFunc func(next RunFunc) RunFunc
@@ -36,12 +38,8 @@ type Container struct {
 	// The muxer long description
 	LongDescription string
 
-	// Commands this muxer will check
-	Commands []*RegisteredCommand
-
-	// Hooks to be ran before executing the command
-	// if the hook returns false, it will not execute any hooks or the command itself after it
-	middlewares []MiddleWareFunc
+	// The *containerState with the commands and middlewares, see state
+	st atomic.Value
 
 	HelpTitleEmoji string
 	HelpColor      int
@@ -56,6 +54,35 @@ var (
 	_ CmdWithDescriptions = (*Container)(nil)
 )
 
+// containerState holds the commands and middlewares of a container, which can be changed while commands are being ran.
+// It's kept behind a pointer so that the lock isn't copied along with the container
+type containerState struct {
+	// Incremented when the middlewares change, see RegisteredCommand.middlewareChain. First for the alignment of 64 bit atomic operations
+	generation uint64
+
+	// Protects the commands and middlewares, they're replaced instead of modified in place so that the slices can be iterated without holding the lock
+	mu sync.RWMutex
+
+	// The commands this muxer will check, use AddCommand, RemoveCommand and ReplaceCommand to change them and ListCommands to read them
+	commands []*RegisteredCommand
+
+	// Hooks to be ran before executing the command
+	// if the hook returns false, it will not execute any hooks or the command itself after it
+	// addedMiddlewares is in the order they were added, and middlewares is the order they're ran in, see AddMiddleware
+	addedMiddlewares []*Middleware
+	middlewares      []*Middleware
+}
+
+// state returns the state of the container, creating it on first use
+func (c *Container) state() *containerState {
+	if s, ok := c.st.Load().(*containerState); ok {
+		return s
+	}
+
+	c.st.CompareAndSwap(nil, &containerState{})
+	return c.st.Load().(*containerState)
+}
+
 func (c *Container) Descriptions(data *Data) (string, string) { return c.Description, c.LongDescription }
 
 func (c *Container) Run(data *Data) (interface{}, error) {
@@ -100,22 +127,8 @@ func (c *Container) Run(data *Data) (interface{}, error) {
 
 	}
 
-	// Build the run chain
-	var last RunFunc = matchingCmd.Command.Run
-
-	// User either prebuilt middleware chain, or build it live
-	if matchingCmd.builtFullMiddlewareChain != nil {
-		last = matchingCmd.builtFullMiddlewareChain
-	} else {
-
-		for i := range matchingCmd.Trigger.Middlewares {
-			last = matchingCmd.Trigger.Middlewares[len(matchingCmd.Trigger.Middlewares)-1-i](last)
-		}
-
-		for i := range data.ContainerChain {
-			last = data.ContainerChain[len(data.ContainerChain)-1-i].BuildMiddlewareChain(last, matchingCmd)
-		}
-	}
+	// Use the cached middleware chain, building it if needed
+	last := matchingCmd.middlewareChain(data.ContainerChain)
 
 	return last(data)
 }
@@ -139,7 +152,7 @@ func (c *Container) FindCommand(searchStr string) (cmd *RegisteredCommand, rest
 	}
 
 	// Start looking for matches in all subcommands
-	for _, c := range c.Commands {
+	for _, c := range c.ListCommands() {
 		names := c.Trigger.Names
 		for _, name := range names {
 			if !strings.EqualFold(name, split[0]) {
@@ -188,38 +201,153 @@ func (c *Container) AbsFindCommandWithRest(searchStr string) (cmd *RegisteredCom
 // Sub returns a copy of the container but with the following attributes overwritten
 // and no commands registered
 func (c *Container) Sub(mainName string, aliases ...string) *Container {
-	cop := new(Container)
-	*cop = *c
-
-	cop.Commands = nil
-	cop.Names = append([]string{mainName}, aliases...)
-	cop.Description = ""
-	cop.LongDescription = ""
-	cop.middlewares = nil
-	cop.Parent = c
+	cop := &Container{
+		DefaultMention:   c.DefaultMention,
+		NotFound:         c.NotFound,
+		DMNotFound:       c.DMNotFound,
+		IgnoreBots:       c.IgnoreBots,
+		SendStackOnPanic: c.SendStackOnPanic,
+		SendError:        c.SendError,
+		RunInDM:          c.RunInDM,
+		HelpTitleEmoji:   c.HelpTitleEmoji,
+		HelpColor:        c.HelpColor,
+		HelpOwnEmbed:     c.HelpOwnEmbed,
+		Category:         c.Category,
+
+		Names:  append([]string{mainName}, aliases...),
+		Parent: c,
+	}
 
 	c.AddCommand(cop, NewTrigger(mainName, aliases...))
 
 	return cop
 }
 
+// ListCommands returns the commands of this container, it's safe to call while commands are being added or removed
+func (c *Container) ListCommands() []*RegisteredCommand {
+	s := c.state()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.commands
+}
+
 func (c *Container) AddCommand(cmd Cmd, trigger *Trigger) *RegisteredCommand {
 	wrapped := &RegisteredCommand{
 		Command: cmd,
 		Trigger: trigger,
 	}
 
-	c.Commands = append(c.Commands, wrapped)
+	s := c.state()
+	s.mu.Lock()
+	s.commands = append(s.commands[:len(s.commands):len(s.commands)], wrapped)
+	s.mu.Unlock()
+
 	return wrapped
 }
 
+// RemoveCommand removes the command from this container, returning false if it was not found
+// It's safe to call while commands are being ran
+func (c *Container) RemoveCommand(cmd *RegisteredCommand) bool {
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, v := range s.commands {
+		if v != cmd {
+			continue
+		}
+
+		commands := make([]*RegisteredCommand, 0, len(s.commands)-1)
+		commands = append(commands, s.commands[:i]...)
+		s.commands = append(commands, s.commands[i+1:]...)
+		return true
+	}
+
+	return false
+}
+
+// ReplaceCommand replaces old with a new command in the same position, returning nil if old was not found
+// It's safe to call while commands are being ran, and commands that are already running will finish using the old one
+func (c *Container) ReplaceCommand(old *RegisteredCommand, cmd Cmd, trigger *Trigger) *RegisteredCommand {
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, v := range s.commands {
+		if v != old {
+			continue
+		}
+
+		wrapped := &RegisteredCommand{
+			Command: cmd,
+			Trigger: trigger,
+		}
+
+		commands := make([]*RegisteredCommand, len(s.commands))
+		copy(commands, s.commands)
+		commands[i] = wrapped
+		s.commands = commands
+		return wrapped
+	}
+
+	return nil
+}
+
+// MiddlewareHandle refers to a middleware added with AddMiddlewareHandles, see RemoveMiddleware
+type MiddlewareHandle struct {
+	mw *Middleware
+}
+
+// AddMidlewares adds unnamed middlewares, see AddMiddlewareHandles to be able to remove them and AddMiddleware for named and ordered ones
 func (c *Container) AddMidlewares(mw ...MiddleWareFunc) {
-	c.middlewares = append(c.middlewares, mw...)
+	c.AddMiddlewareHandles(mw...)
+}
+
+// AddMiddlewareHandles adds unnamed middlewares like AddMidlewares, returning handles that can be used to remove them with RemoveMiddleware
+func (c *Container) AddMiddlewareHandles(mw ...MiddleWareFunc) []MiddlewareHandle {
+	handles := make([]MiddlewareHandle, len(mw))
+
+	s := c.state()
+	s.mu.Lock()
+	added := s.addedMiddlewares[:len(s.addedMiddlewares):len(s.addedMiddlewares)]
+	for i, v := range mw {
+		handles[i].mw = &Middleware{Func: v}
+		added = append(added, handles[i].mw)
+	}
+
+	// The middlewares that were already added could be ordered, and unnamed middlewares have no Before or After constraints
+	// and can't be referred to by the constraints of others, so adding them can't introduce a cycle and this can't fail
+	s.setMiddlewares(added)
+	s.mu.Unlock()
+
+	return handles
+}
+
+// RemoveMiddleware removes the middleware added with AddMiddlewareHandles, returning false if it was not found
+func (c *Container) RemoveMiddleware(handle MiddlewareHandle) bool {
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, v := range s.addedMiddlewares {
+		if v == handle.mw {
+			s.removeMiddleware(i)
+			return true
+		}
+	}
+
+	return false
 }
 
 func (c *Container) BuildMiddlewareChain(r RunFunc, cmd *RegisteredCommand) RunFunc {
-	for i := range c.middlewares {
-		r = c.middlewares[len(c.middlewares)-1-i](r)
+	s := c.state()
+	s.mu.RLock()
+	middlewares := s.middlewares
+	s.mu.RUnlock()
+
+	for i := range middlewares {
+		r = middlewares[len(middlewares)-1-i].Func(r)
 	}
 
 	return r
@@ -254,25 +382,89 @@ func (c *Container) FullName(aliases bool) string {
 }
 
 // BuildMiddlewareChains builds all the middleware chains and chaches them.
-// It is reccomended to call this after adding all commands and middleware to avoid building the chains everytime
-// a command is invoked
+// This is not required as the chains are built on the first invocation of a command and rebuilt after any changes,
+// but it can be called after adding all commands and middleware to avoid building them while handling commands.
 func (c *Container) BuildMiddlewareChains(containerChain []*Container) {
 	containerChain = append(containerChain, c)
-	for _, cmd := range c.Commands {
+	for _, cmd := range c.ListCommands() {
 		if cast, ok := cmd.Command.(*Container); ok {
 			cast.BuildMiddlewareChains(containerChain)
 			continue
 		}
 
-		last := cmd.Command.Run
+		cmd.middlewareChain(containerChain)
+	}
+}
 
-		for i := range cmd.Trigger.Middlewares {
-			last = cmd.Trigger.Middlewares[len(cmd.Trigger.Middlewares)-1-i](last)
+// chainGeneration is incremented by InvalidateMiddlewareChains, the cached chains built in a older generation are rebuilt
+var chainGeneration uint64
+
+// InvalidateMiddlewareChains makes all the cached middleware chains be rebuilt on the next invocation.
+// Changes to the middlewares of containers and triggers are detected and only cause the chains that include them to be rebuilt,
+// so this is only needed if a middleware builds its RunFunc from something that has changed since.
+func InvalidateMiddlewareChains() {
+	atomic.AddUint64(&chainGeneration, 1)
+}
+
+type builtChain struct {
+	generation         uint64
+	triggerMiddlewares *triggerMiddlewares
+
+	// The containers the chain was built from and their generations at the time
+	containers  []*Container
+	generations []uint64
+
+	run RunFunc
+}
+
+// upToDate returns true if the chain was built from containerChain and nothing in it has changed since
+func (b *builtChain) upToDate(generation uint64, triggerMiddlewares *triggerMiddlewares, containerChain []*Container) bool {
+	if b.generation != generation || b.triggerMiddlewares != triggerMiddlewares || len(b.containers) != len(containerChain) {
+		return false
+	}
+
+	for i, c := range containerChain {
+		if b.containers[i] != c || b.generations[i] != atomic.LoadUint64(&c.state().generation) {
+			return false
 		}
+	}
 
-		for i := range containerChain {
-			last = containerChain[len(containerChain)-1-i].BuildMiddlewareChain(last, cmd)
+	return true
+}
+
+// middlewareChain returns the cached middleware chain of the command, building it if it's not built, outdated
+// or was built from a different container chain
+func (r *RegisteredCommand) middlewareChain(containerChain []*Container) RunFunc {
+	// Load the generations before building, so that changes made while building cause another rebuild
+	generation := atomic.LoadUint64(&chainGeneration)
+	triggerMiddlewares := r.Trigger.middlewares()
+	if built, ok := r.builtChain.Load().(*builtChain); ok && built.upToDate(generation, triggerMiddlewares, containerChain) {
+		return built.run
+	}
+
+	built := &builtChain{
+		generation:         generation,
+		triggerMiddlewares: triggerMiddlewares,
+		containers:         append([]*Container(nil), containerChain...),
+		generations:        make([]uint64, len(containerChain)),
+	}
+	for i, c := range containerChain {
+		built.generations[i] = atomic.LoadUint64(&c.state().generation)
+	}
+
+	last := r.Command.Run
+
+	if triggerMiddlewares != nil {
+		for i := range triggerMiddlewares.funcs {
+			last = triggerMiddlewares.funcs[len(triggerMiddlewares.funcs)-1-i](last)
 		}
-		cmd.builtFullMiddlewareChain = last
 	}
+
+	for i := range containerChain {
+		last = containerChain[len(containerChain)-1-i].BuildMiddlewareChain(last, r)
+	}
+
+	built.run = last
+	r.builtChain.Store(built)
+	return last
 }
@@ -0,0 +1,113 @@
+package dcmd
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type respondCommand string
+
+func (r respondCommand) Run(data *Data) (interface{}, error) {
+	return string(r), nil
+}
+
+func TestRemoveReplaceCommand(t *testing.T) {
+	container := &Container{}
+	first := container.AddCommand(respondCommand("first"), NewTrigger("first"))
+	second := container.AddCommand(respondCommand("second"), NewTrigger("second"))
+
+	if !container.RemoveCommand(first) {
+		t.Error("RemoveCommand did not find the command")
+	}
+	if container.RemoveCommand(first) {
+		t.Error("RemoveCommand removed the same command twice")
+	}
+
+	if cmd, _ := container.FindCommand("first"); cmd != nil {
+		t.Error("Found removed command")
+	}
+
+	replaced := container.ReplaceCommand(second, respondCommand("replaced"), NewTrigger("second"))
+	if replaced == nil {
+		t.Fatal("ReplaceCommand did not find the command")
+	}
+
+	resp, _ := container.Run(&Data{MsgStrippedPrefix: "second", Source: PrefixSource})
+	if resp != "replaced" {
+		t.Error("Response:", resp, "expected replaced")
+	}
+
+	if container.ReplaceCommand(second, respondCommand("again"), NewTrigger("second")) != nil {
+		t.Error("ReplaceCommand replaced a command that was already replaced")
+	}
+}
+
+func countingMiddleware(calls *int) MiddleWareFunc {
+	return func(inner RunFunc) RunFunc {
+		return func(d *Data) (interface{}, error) {
+			*calls++
+			return inner(d)
+		}
+	}
+}
+
+func TestRemoveMiddleware(t *testing.T) {
+	container := &Container{}
+	container.AddCommand(respondCommand("resp"), NewTrigger("test"))
+
+	// both are closures of the same function literal
+	firstCalls, secondCalls := 0, 0
+	container.AddMidlewares(countingMiddleware(&firstCalls))
+	handles := container.AddMiddlewareHandles(countingMiddleware(&secondCalls))
+
+	container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+	if !container.RemoveMiddleware(handles[0]) {
+		t.Error("RemoveMiddleware did not find the middleware")
+	}
+	if container.RemoveMiddleware(handles[0]) {
+		t.Error("RemoveMiddleware removed the same middleware twice")
+	}
+	container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+
+	if firstCalls != 2 {
+		t.Error("First calls:", firstCalls, "expected 2")
+	}
+	if secondCalls != 1 {
+		t.Error("Second calls:", secondCalls, "expected 1")
+	}
+}
+
+func TestConcurrentMutation(t *testing.T) {
+	container := &Container{}
+	container.AddCommand(respondCommand("resp"), NewTrigger("test"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				resp, _ := container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+				if resp != "resp" {
+					t.Error("Response:", resp, "expected resp")
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		cmd := container.AddCommand(respondCommand("other"), NewTrigger("other"+strconv.Itoa(i)))
+		sub := container.Sub("sub" + strconv.Itoa(i))
+		sub.AddCommand(respondCommand("sub"), NewTrigger("test"))
+
+		handles := container.AddMiddlewareHandles(func(inner RunFunc) RunFunc { return inner })
+		container.RemoveMiddleware(handles[0])
+		container.ListCommands()[0].Trigger.SetMiddlewares(func(inner RunFunc) RunFunc { return inner })
+		container.RemoveCommand(cmd)
+		container.ReplaceCommand(container.ListCommands()[0], respondCommand("resp"), NewTrigger("test"))
+	}
+
+	wg.Wait()
+}
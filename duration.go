@@ -0,0 +1,143 @@
+package dcmd
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DurationUnits are the units accepted by DurationArg, matching is case insensitive
+var DurationUnits = map[string]time.Duration{
+	"w": time.Hour * 24 * 7, "week": time.Hour * 24 * 7, "weeks": time.Hour * 24 * 7,
+	"d": time.Hour * 24, "day": time.Hour * 24, "days": time.Hour * 24,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"ms": time.Millisecond,
+}
+
+// DurationArg matches and parses durations such as "1h30m", "2d" or "1.5w", returning a time.Duration
+// Plain numbers are in DefaultUnit, or minutes if it's not set
+// If Min or Max is not 0 then the duration can't be shorter or longer than it
+type DurationArg struct {
+	Min, Max    time.Duration
+	DefaultUnit time.Duration
+}
+
+func (d *DurationArg) Matches(def *ArgDef, part string) bool {
+	_, ok := d.parse(part)
+	return ok
+}
+
+func (d *DurationArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	v, ok := d.parse(part)
+	if !ok {
+		return nil, &InvalidDuration{part}
+	}
+
+	if (d.Min != 0 && v < d.Min) || (d.Max != 0 && v > d.Max) {
+		err := &OutOfRangeError{ArgName: argName(def), Got: v}
+		if d.Min != 0 {
+			err.Min = d.Min
+		}
+		if d.Max != 0 {
+			err.Max = d.Max
+		}
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (d *DurationArg) parse(part string) (time.Duration, bool) {
+	part = strings.ToLower(part)
+	if part == "" {
+		return 0, false
+	}
+
+	if numberEnd(part) == len(part) {
+		unit := d.DefaultUnit
+		if unit == 0 {
+			unit = time.Minute
+		}
+
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return scaleDuration(n, unit)
+	}
+
+	var total time.Duration
+	for part != "" {
+		numEnd := numberEnd(part)
+		if numEnd < 1 {
+			return 0, false
+		}
+
+		n, err := strconv.ParseFloat(part[:numEnd], 64)
+		if err != nil {
+			return 0, false
+		}
+		part = part[numEnd:]
+
+		unitEnd := strings.IndexFunc(part, func(r rune) bool { return r < 'a' || r > 'z' })
+		if unitEnd == -1 {
+			unitEnd = len(part)
+		}
+
+		unit, ok := DurationUnits[part[:unitEnd]]
+		if !ok {
+			return 0, false
+		}
+		part = part[unitEnd:]
+
+		v, ok := scaleDuration(n, unit)
+		if !ok || v > math.MaxInt64-total {
+			return 0, false
+		}
+		total += v
+	}
+
+	return total, true
+}
+
+// numberEnd returns the length of the number at the start of part, only digits and dots are allowed
+// so signs, exponents and things like "inf" are not accepted
+func numberEnd(part string) int {
+	end := strings.IndexFunc(part, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if end == -1 {
+		return len(part)
+	}
+
+	return end
+}
+
+// scaleDuration returns n units, ok is false if n is negative or not finite, or if the result overflows
+func scaleDuration(n float64, unit time.Duration) (time.Duration, bool) {
+	if n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+
+	v := n * float64(unit)
+	if v >= math.MaxInt64 {
+		return 0, false
+	}
+
+	return time.Duration(v), true
+}
+
+func (d *DurationArg) HelpName() string {
+	return "Duration"
+}
+
+func (d *DurationArg) ValueType() reflect.Type {
+	return reflect.TypeOf(time.Duration(0))
+}
+
+func (d *DurationArg) MatchScore(def *ArgDef, part string) int {
+	return ScoreNumber
+}
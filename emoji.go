@@ -0,0 +1,245 @@
+package dcmd
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	customEmojiRegex     = regexp.MustCompile(`^<(a?):([A-Za-z0-9_~]{2,32}):(\d+)>$`)
+	customEmojiNameRegex = regexp.MustCompile(`^:?([A-Za-z0-9_~]{2,32}):?$`)
+)
+
+// EmojiMatch is the result of a EmojiArg, it can either be a custom emoji or a unicode emoji
+type EmojiMatch struct {
+	// ID is 0 for unicode emojis
+	ID int64
+	// Name is the custom emoji name, or the emoji itself if it's a unicode emoji
+	Name     string
+	Animated bool
+}
+
+// IsUnicode returns true if this is a unicode emoji and not a custom one
+func (e *EmojiMatch) IsUnicode() bool {
+	return e.ID == 0
+}
+
+// APIName returns the name in the format used for reactions
+func (e *EmojiMatch) APIName() string {
+	if e.IsUnicode() {
+		return e.Name
+	}
+
+	return e.Name + ":" + strconv.FormatInt(e.ID, 10)
+}
+
+// MessageFormat returns the emoji in the format used in messages
+func (e *EmojiMatch) MessageFormat() string {
+	if e.IsUnicode() {
+		return e.Name
+	}
+
+	prefix := "<:"
+	if e.Animated {
+		prefix = "<a:"
+	}
+
+	return prefix + e.Name + ":" + strconv.FormatInt(e.ID, 10) + ">"
+}
+
+// EmojiArg matches and parses custom emojis (<:name:id> and <a:name:id>) and unicode emojis, returning a *EmojiMatch
+// If EnableNameSearch is set it will also search the guilds emojis by name (either "name" or ":name:")
+type EmojiArg struct {
+	EnableNameSearch bool
+	DisableUnicode   bool
+}
+
+func (e *EmojiArg) Matches(def *ArgDef, part string) bool {
+	if customEmojiRegex.MatchString(part) {
+		return true
+	}
+
+	if !e.DisableUnicode && IsUnicodeEmoji(part) {
+		return true
+	}
+
+	if e.EnableNameSearch && customEmojiNameRegex.MatchString(part) {
+		return true
+	}
+
+	return false
+}
+
+func (e *EmojiArg) Parse(def *ArgDef, part string, data *Data) (interface{}, error) {
+	if m := customEmojiRegex.FindStringSubmatch(part); m != nil {
+		id, err := strconv.ParseInt(m[3], 10, 64)
+		if err != nil {
+			return nil, &InvalidEmoji{part}
+		}
+
+		return &EmojiMatch{
+			ID:       id,
+			Name:     m[2],
+			Animated: m[1] == "a",
+		}, nil
+	}
+
+	if !e.DisableUnicode && IsUnicodeEmoji(part) {
+		return &EmojiMatch{Name: part}, nil
+	}
+
+	if e.EnableNameSearch && data.GS != nil {
+		if m := customEmojiNameRegex.FindStringSubmatch(part); m != nil {
+			if found := e.findGuildEmoji(data, m[1]); found != nil {
+				return found, nil
+			}
+		}
+	}
+
+	return nil, &InvalidEmoji{part}
+}
+
+func (e *EmojiArg) findGuildEmoji(data *Data, name string) *EmojiMatch {
+	data.GS.RLock()
+	defer data.GS.RUnlock()
+
+	if data.GS.Guild == nil {
+		return nil
+	}
+
+	var partial *EmojiMatch
+	for _, v := range data.GS.Guild.Emojis {
+		if v.Name == name {
+			return &EmojiMatch{ID: v.ID, Name: v.Name, Animated: v.Animated}
+		}
+
+		if partial == nil && strings.EqualFold(v.Name, name) {
+			partial = &EmojiMatch{ID: v.ID, Name: v.Name, Animated: v.Animated}
+		}
+	}
+
+	return partial
+}
+
+func (e *EmojiArg) HelpName() string {
+	if e.DisableUnicode {
+		return "Custom emoji"
+	}
+
+	return "Emoji"
+}
+
+func (e *EmojiArg) ValueType() reflect.Type {
+	return reflect.TypeOf((*EmojiMatch)(nil))
+}
+
+// IsUnicodeEmoji returns true if s consists of a single unicode emoji sequence
+// this includes zero width joined sequences, skin tone modifiers, flags and keycaps
+func IsUnicodeEmoji(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	// whether we have a emoji the next components can be applied to
+	haveBase := false
+	regionalIndicators := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError {
+			return false
+		}
+
+		switch {
+		case r == 0x200D:
+			// zero width joiner, the next rune has to start a new emoji joined to this one
+			if !haveBase {
+				return false
+			}
+			haveBase = false
+			regionalIndicators = 0
+		case isEmojiComponent(r):
+			if !haveBase {
+				return false
+			}
+		case haveBase && !(isRegionalIndicator(r) && regionalIndicators == 1):
+			// a new emoji without a joiner, so this is more than one emoji
+			return false
+		case isEmojiKeycapBase(r):
+			// keycaps, the base has to be followed by a optional variation selector and the keycap
+			rest := strings.TrimPrefix(s[i+size:], "\uFE0F")
+			if !strings.HasPrefix(rest, "\u20E3") {
+				return false
+			}
+			i = len(s) - len(rest) + len("\u20E3")
+			haveBase = true
+			continue
+		case isEmojiRune(r):
+			haveBase = true
+			if isRegionalIndicator(r) {
+				regionalIndicators++
+			}
+		default:
+			return false
+		}
+
+		i += size
+	}
+
+	return haveBase
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func isEmojiKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+// isEmojiComponent returns true for runes that modify emojis but are not emojis on their own
+func isEmojiComponent(r rune) bool {
+	switch {
+	case r == 0xFE0E || r == 0xFE0F: // variation selectors
+	case r == 0x20E3: // combining keycap
+	case r >= 0x1F3FB && r <= 0x1F3FF: // skin tones
+	case r >= 0xE0020 && r <= 0xE007F: // tags, used in subdivision flags
+	default:
+		return false
+	}
+
+	return true
+}
+
+var emojiRanges = [][2]rune{
+	{0x00A9, 0x00A9},
+	{0x00AE, 0x00AE},
+	{0x203C, 0x203C},
+	{0x2049, 0x2049},
+	{0x2122, 0x2122},
+	{0x2139, 0x2139},
+	{0x2194, 0x21AA},
+	{0x231A, 0x23FF},
+	{0x24C2, 0x24C2},
+	{0x25AA, 0x25FE},
+	{0x2600, 0x27BF},
+	{0x2934, 0x2935},
+	{0x2B05, 0x2B55},
+	{0x3030, 0x3030},
+	{0x303D, 0x303D},
+	{0x3297, 0x3297},
+	{0x3299, 0x3299},
+	{0x1F000, 0x1FAFF},
+}
+
+func isEmojiRune(r rune) bool {
+	for _, v := range emojiRanges {
+		if r >= v[0] && r <= v[1] {
+			return true
+		}
+	}
+
+	return false
+}
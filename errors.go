@@ -2,7 +2,10 @@ package dcmd
 
 import (
 	"fmt"
+	"github.com/jonas747/discordgo"
 	"github.com/pkg/errors"
+	"strings"
+	"time"
 )
 
 type InvalidInt struct {
@@ -29,6 +32,19 @@ func (i *InvalidFloat) IsUserError() bool {
 	return true
 }
 
+type InvalidBool struct {
+	Part                  string
+	TrueWords, FalseWords []string
+}
+
+func (i *InvalidBool) Error() string {
+	return fmt.Sprintf("%q is not one of %s or %s", i.Part, strings.Join(i.TrueWords, "/"), strings.Join(i.FalseWords, "/"))
+}
+
+func (i *InvalidBool) IsUserError() bool {
+	return true
+}
+
 type ImproperMention struct {
 	Part string
 }
@@ -41,6 +57,18 @@ func (i *ImproperMention) IsUserError() bool {
 	return true
 }
 
+type InvalidEmoji struct {
+	Part string
+}
+
+func (i *InvalidEmoji) Error() string {
+	return fmt.Sprintf("%q is not a valid emoji", i.Part)
+}
+
+func (i *InvalidEmoji) IsUserError() bool {
+	return true
+}
+
 type NoMention struct {
 	Part string
 }
@@ -77,6 +105,106 @@ func (c *ChannelNotFound) IsUserError() bool {
 	return true
 }
 
+type ChannelNameNotFound struct {
+	Part string
+}
+
+func (c *ChannelNameNotFound) Error() string {
+	return fmt.Sprintf("Channel %q not found", c.Part)
+}
+
+func (c *ChannelNameNotFound) IsUserError() bool {
+	return true
+}
+
+type InvalidChannelType struct {
+	Channel string
+	Got     discordgo.ChannelType
+	Allowed []discordgo.ChannelType
+}
+
+func (i *InvalidChannelType) Error() string {
+	return fmt.Sprintf("#%s is a %s channel, has to be a %s channel", i.Channel, strings.ToLower(channelTypeName(i.Got)), strings.ToLower(formatChannelTypes(i.Allowed)))
+}
+
+func (i *InvalidChannelType) IsUserError() bool {
+	return true
+}
+
+type InvalidMessageRef struct {
+	Part string
+}
+
+func (i *InvalidMessageRef) Error() string {
+	return fmt.Sprintf("%q is not a message link or ID", i.Part)
+}
+
+func (i *InvalidMessageRef) IsUserError() bool {
+	return true
+}
+
+type MessageNotFound struct {
+	ID int64
+}
+
+func (m *MessageNotFound) Error() string {
+	return fmt.Sprintf("Message %d not found", m.ID)
+}
+
+func (m *MessageNotFound) IsUserError() bool {
+	return true
+}
+
+type MissingSwitchValue struct {
+	Switch   string
+	TypeName string
+}
+
+func (m *MissingSwitchValue) Error() string {
+	return fmt.Sprintf("Switch -%s requires a value (%s)", m.Switch, m.TypeName)
+}
+
+func (m *MissingSwitchValue) IsUserError() bool {
+	return true
+}
+
+type MissingRequiredSwitch struct {
+	Switch string
+}
+
+func (m *MissingRequiredSwitch) Error() string {
+	return fmt.Sprintf("Switch -%s is required", m.Switch)
+}
+
+func (m *MissingRequiredSwitch) IsUserError() bool {
+	return true
+}
+
+type ExclusiveSwitches struct {
+	A, B string
+}
+
+func (e *ExclusiveSwitches) Error() string {
+	return fmt.Sprintf("-%s and -%s cannot be used together", e.A, e.B)
+}
+
+func (e *ExclusiveSwitches) IsUserError() bool {
+	return true
+}
+
+type SwitchRequires struct {
+	Switch   string
+	Requires string
+}
+
+func (s *SwitchRequires) Error() string {
+	return fmt.Sprintf("-%s can only be used together with -%s", s.Switch, s.Requires)
+}
+
+func (s *SwitchRequires) IsUserError() bool {
+	return true
+}
+
 type OutOfRangeError struct {
 	Min, Max interface{}
 	Got      interface{}
@@ -89,29 +217,106 @@ func (o *OutOfRangeError) Error() string {
 
 	switch o.Got.(type) {
 	case int64:
-		if o.Got.(int64) < o.Min.(int64) {
+		if min, ok := o.Min.(int64); ok && o.Got.(int64) < min {
 			preStr = "too small"
 		}
 	case float64:
-		if o.Got.(float64) < o.Min.(float64) {
+		if min, ok := o.Min.(float64); ok && o.Got.(float64) < min {
+			preStr = "too small"
+		}
+	case time.Duration:
+		if min, ok := o.Min.(time.Duration); ok && o.Got.(time.Duration) < min {
 			preStr = "too small"
 		}
 	}
 
-	const floatFormat = "%s is %s (has to be within %f - %f)"
-	const intFormat = "%s is %s (has to be within %d - %d)"
-
+	format := "%d"
 	if o.Float {
-		return fmt.Sprintf(floatFormat, o.ArgName, preStr, o.Min, o.Max)
+		format = "%f"
+	} else if _, ok := o.Got.(time.Duration); ok {
+		format = "%s"
+	}
+
+	// Min or Max is nil for one sided bounds
+	switch {
+	case o.Max == nil:
+		return fmt.Sprintf("%s is %s (has to be at least "+format+")", o.ArgName, preStr, o.Min)
+	case o.Min == nil:
+		return fmt.Sprintf("%s is %s (has to be at most "+format+")", o.ArgName, preStr, o.Max)
 	}
 
-	return fmt.Sprintf(intFormat, o.ArgName, preStr, o.Min, o.Max)
+	return fmt.Sprintf("%s is %s (has to be within "+format+" - "+format+")", o.ArgName, preStr, o.Min, o.Max)
 }
 
 func (o *OutOfRangeError) IsUserError() bool {
 	return true
 }
 
+type InvalidLength struct {
+	ArgName  string
+	Got      int
+	Min, Max int
+}
+
+func (i *InvalidLength) Error() string {
+	switch {
+	case i.Min != 0 && i.Max != 0:
+		return fmt.Sprintf("%s has to be within %d - %d characters long (was %d)", i.ArgName, i.Min, i.Max, i.Got)
+	case i.Max != 0:
+		return fmt.Sprintf("%s can't be longer than %d characters (was %d)", i.ArgName, i.Max, i.Got)
+	}
+
+	return fmt.Sprintf("%s has to be atleast %d characters long (was %d)", i.ArgName, i.Min, i.Got)
+}
+
+func (i *InvalidLength) IsUserError() bool {
+	return true
+}
+
+type PatternMismatch struct {
+	ArgName     string
+	Part        string
+	PatternHelp string
+}
+
+func (p *PatternMismatch) Error() string {
+	if p.PatternHelp != "" {
+		return fmt.Sprintf("%s is not valid, has to be %s", p.ArgName, p.PatternHelp)
+	}
+
+	return fmt.Sprintf("%s is not in the right format", p.ArgName)
+}
+
+func (p *PatternMismatch) IsUserError() bool {
+	return true
+}
+
+type MassMentionNotAllowed struct {
+	ArgName string
+}
+
+func (m *MassMentionNotAllowed) Error() string {
+	return fmt.Sprintf("%s can't contain @everyone or @here mentions", m.ArgName)
+}
+
+func (m *MassMentionNotAllowed) IsUserError() bool {
+	return true
+}
+
+// ArgValidationError is returned when one of the validators of a ArgDef failed
+type ArgValidationError struct {
+	ArgName string
+	Err     error
+}
+
+func (a *ArgValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", a.ArgName, a.Err)
+}
+
+func (a *ArgValidationError) IsUserError() bool {
+	return true
+}
+
 type UserError interface {
 	IsUserError() bool
 }
@@ -138,3 +343,55 @@ func (s simpleUserError) IsUserError() bool {
 func NewSimpleUserError(args ...interface{}) error {
 	return simpleUserError(fmt.Sprint(args...))
 }
+
+// NoComboFound is returned by ParseArgDefs when none of the combos of the command matched,
+// Candidates are the combos that were considered along with why they were rejected, see FormatCandidates.
+// Its cause is ErrNoComboFound, so errors.Cause(err) == ErrNoComboFound still works.
+type NoComboFound struct {
+	Candidates []*ComboMatch
+}
+
+func (n *NoComboFound) Error() string {
+	return ErrNoComboFound.Error()
+}
+
+func (n *NoComboFound) Cause() error {
+	return ErrNoComboFound
+}
+
+type InvalidAttachment struct {
+	Filename string
+	Reason   string
+}
+
+func (i *InvalidAttachment) Error() string {
+	return fmt.Sprintf("%q %s", i.Filename, i.Reason)
+}
+
+func (i *InvalidAttachment) IsUserError() bool {
+	return true
+}
+
+type MissingAttachment struct {
+	ArgName string
+}
+
+func (m *MissingAttachment) Error() string {
+	return "Missing attachment for " + m.ArgName
+}
+
+func (m *MissingAttachment) IsUserError() bool {
+	return true
+}
+
+type InvalidDuration struct {
+	Part string
+}
+
+func (i *InvalidDuration) Error() string {
+	return fmt.Sprintf("%q is not a duration, try something like 1h30m", i.Part)
+}
+
+func (i *InvalidDuration) IsUserError() bool {
+	return true
+}
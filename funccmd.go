@@ -0,0 +1,355 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/pkg/errors"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// FuncArg is optional metadata for a parameter of the function passed to NewFuncCmd
+type FuncArg struct {
+	Name string
+	Help string
+
+	// Type overrides the ArgType picked from the type of the parameter, it has to produce values that can be converted to the parameter type
+	Type ArgType
+}
+
+// FuncCmd is a command created from a plain function by NewFuncCmd
+type FuncCmd struct {
+	ShortDesc, LongDesc string
+
+	CmdSwitches []*ArgDef
+
+	fn       reflect.Value
+	withData bool
+	defs     []*ArgDef
+	required int
+}
+
+var (
+	_ Cmd                 = (*FuncCmd)(nil)
+	_ CmdWithArgDefs      = (*FuncCmd)(nil)
+	_ CmdWithDescriptions = (*FuncCmd)(nil)
+	_ CmdWithSwitches     = (*FuncCmd)(nil)
+)
+
+var (
+	dataType  = reflect.TypeOf((*Data)(nil))
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+	// funcArgTypes maps parameter types to the ArgTypes used for them by NewFuncCmd
+	funcArgTypes = map[reflect.Type]ArgType{
+		reflect.TypeOf(int64(0)):                            Int,
+		reflect.TypeOf(int(0)):                              Int,
+		reflect.TypeOf(int32(0)):                            Int,
+		reflect.TypeOf(float64(0)):                          Float,
+		reflect.TypeOf(float32(0)):                          Float,
+		reflect.TypeOf(false):                               Bool,
+		reflect.TypeOf(""):                                  String,
+		reflect.TypeOf(time.Duration(0)):                    Duration,
+		reflect.TypeOf((*discordgo.User)(nil)):              User,
+		reflect.TypeOf((*dstate.MemberState)(nil)):          AdvUser,
+		reflect.TypeOf((*AdvUserMatch)(nil)):                AdvUser,
+		reflect.TypeOf((*dstate.ChannelState)(nil)):         Channel,
+		reflect.TypeOf((*EmojiMatch)(nil)):                  Emoji,
+		reflect.TypeOf((*MessageRef)(nil)):                  Message,
+		reflect.TypeOf((*discordgo.MessageAttachment)(nil)): Attachment,
+	}
+)
+
+// NewFuncCmd creates a command from fn, with the arg defs made from the parameters of fn.
+//
+// fn has to return (interface{}, error) (any type can be used in place of interface{}), and can optionally take the *Data as the first parameter.
+// The rest of the parameters are mapped to the built in ArgTypes, e.g int64 to Int, *discordgo.User to User and *dstate.ChannelState to Channel.
+// A string as the last parameter is a Remainder, slices and variadic parameters are variadic args,
+// and pointers to basic types (e.g *int64) are optional args which are nil if omitted, only the last parameters can be optional.
+//
+// Names and help for the args can be provided with args, in the order of the parameters (not counting *Data), by default they're named "Arg1", "Arg2" and so on.
+//
+//	cmd, err := dcmd.NewFuncCmd(func(data *dcmd.Data, user *discordgo.User, days int64, reason string) (interface{}, error) {
+//		...
+//	}, &dcmd.FuncArg{Name: "User"}, &dcmd.FuncArg{Name: "Days"}, &dcmd.FuncArg{Name: "Reason"})
+func NewFuncCmd(fn interface{}, args ...*FuncArg) (*FuncCmd, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, errors.Errorf("NewFuncCmd: %T is not a function", fn)
+	}
+
+	t := v.Type()
+	if t.NumOut() != 2 || t.Out(1) != errorType {
+		return nil, errors.Errorf("NewFuncCmd: %s has to return (interface{}, error)", t)
+	}
+
+	cmd := &FuncCmd{fn: v}
+
+	params := make([]reflect.Type, 0, t.NumIn())
+	for i := 0; i < t.NumIn(); i++ {
+		params = append(params, t.In(i))
+	}
+
+	if len(params) > 0 && params[0] == dataType {
+		cmd.withData = true
+		params = params[1:]
+	}
+
+	if len(args) > len(params) {
+		return nil, errors.Errorf("NewFuncCmd: %d FuncArgs for %d parameters", len(args), len(params))
+	}
+
+	cmd.defs = make([]*ArgDef, len(params))
+	cmd.required = len(params)
+	for i, param := range params {
+		def := &ArgDef{Name: "Arg" + strconv.Itoa(i+1)}
+		var meta *FuncArg
+		if i < len(args) && args[i] != nil {
+			meta = args[i]
+			if meta.Name != "" {
+				def.Name = meta.Name
+			}
+			def.Help = meta.Help
+		}
+
+		valueType := param
+		switch {
+		case param.Kind() == reflect.Slice:
+			def.Variadic = true
+			valueType = param.Elem()
+		case param.Kind() == reflect.Ptr && param.Elem().Kind() != reflect.Struct:
+			// optional
+			valueType = param.Elem()
+			if cmd.required == len(params) {
+				cmd.required = i
+			}
+		case cmd.required != len(params):
+			return nil, errors.Errorf("NewFuncCmd: parameter %d (%s) is required but comes after a optional one", i+1, param)
+		}
+
+		if meta != nil && meta.Type != nil {
+			def.Type = meta.Type
+		} else if valueType.Kind() == reflect.String && i == len(params)-1 && !def.Variadic {
+			def.Type = Remainder
+		} else if argType, ok := funcArgTypes[valueType]; ok {
+			def.Type = argType
+		} else {
+			return nil, errors.Errorf("NewFuncCmd: no ArgType for parameter %d (%s)", i+1, param)
+		}
+
+		if typed, ok := def.Type.(TypedArgType); ok && !funcArgConvertible(typed.ValueType(), valueType) {
+			return nil, errors.Errorf("NewFuncCmd: arg %s (%s) has values of type %s which can't be used for parameter %d (%s)", def.Name, def.Type.HelpName(), typed.ValueType(), i+1, param)
+		}
+
+		cmd.defs[i] = def
+	}
+
+	return cmd, nil
+}
+
+// MustFuncCmd is like NewFuncCmd but panics on errors
+func MustFuncCmd(fn interface{}, args ...*FuncArg) *FuncCmd {
+	cmd, err := NewFuncCmd(fn, args...)
+	if err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
+
+func (f *FuncCmd) Run(data *Data) (interface{}, error) {
+	t := f.fn.Type()
+
+	in := make([]reflect.Value, 0, t.NumIn())
+	if f.withData {
+		in = append(in, reflect.ValueOf(data))
+	}
+
+	for i := range f.defs {
+		param := t.In(len(in))
+
+		var val interface{}
+		if i < len(data.Args) {
+			val = data.Args[i].Value
+		}
+
+		v, err := funcArgValue(val, param)
+		if err != nil {
+			if oe, ok := err.(*OutOfRangeError); ok {
+				oe.ArgName = argName(f.defs[i])
+				return nil, oe
+			}
+
+			return nil, errors.WithMessage(err, "arg "+f.defs[i].Name)
+		}
+		in = append(in, v)
+	}
+
+	var out []reflect.Value
+	if t.IsVariadic() {
+		out = f.fn.CallSlice(in)
+	} else {
+		out = f.fn.Call(in)
+	}
+
+	err, _ := out[1].Interface().(error)
+	return out[0].Interface(), err
+}
+
+func (f *FuncCmd) Descriptions(data *Data) (short, long string) {
+	return f.ShortDesc, f.LongDesc
+}
+
+func (f *FuncCmd) ArgDefs(data *Data) (args []*ArgDef, required int, combos [][]int) {
+	return f.defs, f.required, nil
+}
+
+func (f *FuncCmd) Switches() []*ArgDef {
+	return f.CmdSwitches
+}
+
+var (
+	memberStateType  = reflect.TypeOf((*dstate.MemberState)(nil))
+	advUserMatchType = reflect.TypeOf((*AdvUserMatch)(nil))
+	userType         = reflect.TypeOf((*discordgo.User)(nil))
+)
+
+// funcArgConvertible returns true if values of type from can be used for parameters of type to by funcArgValue
+func funcArgConvertible(from, to reflect.Type) bool {
+	if from == advUserMatchType && (to == memberStateType || to == userType) {
+		return true
+	}
+
+	if from.AssignableTo(to) {
+		return true
+	}
+
+	// Only convert between numbers
+	return isNumberKind(from.Kind()) && isNumberKind(to.Kind())
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	return isIntKind(k) || isUintKind(k) || k == reflect.Float32 || k == reflect.Float64
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+
+	return false
+}
+
+func isUintKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+
+	return false
+}
+
+// convertNumber converts the number rv to typ, returning a OutOfRangeError if it doesn't fit in typ
+func convertNumber(rv reflect.Value, typ reflect.Type) (reflect.Value, error) {
+	target := reflect.Zero(typ)
+	fits := true
+	switch {
+	case isIntKind(rv.Kind()):
+		i := rv.Int()
+		if isIntKind(typ.Kind()) {
+			fits = !target.OverflowInt(i)
+		} else if isUintKind(typ.Kind()) {
+			fits = i >= 0 && !target.OverflowUint(uint64(i))
+		}
+	case isUintKind(rv.Kind()):
+		u := rv.Uint()
+		if isIntKind(typ.Kind()) {
+			fits = u <= math.MaxInt64 && !target.OverflowInt(int64(u))
+		} else if isUintKind(typ.Kind()) {
+			fits = !target.OverflowUint(u)
+		}
+	default:
+		f := rv.Float()
+		bits := float64(typ.Bits())
+		if isIntKind(typ.Kind()) {
+			fits = f >= -math.Pow(2, bits-1) && f < math.Pow(2, bits-1)
+		} else if isUintKind(typ.Kind()) {
+			fits = f >= 0 && f < math.Pow(2, bits)
+		} else {
+			fits = !target.OverflowFloat(f)
+		}
+	}
+
+	if fits {
+		return rv.Convert(typ), nil
+	}
+
+	err := &OutOfRangeError{Got: rv.Interface()}
+	switch {
+	case isIntKind(typ.Kind()):
+		err.Min, err.Max = int64(-1)<<(typ.Bits()-1), int64(1)<<(typ.Bits()-1)-1
+	case isUintKind(typ.Kind()):
+		err.Min, err.Max = int64(0), uint64(math.MaxUint64)>>(64-typ.Bits())
+	default:
+		err.Min, err.Max, err.Float = -math.MaxFloat32, math.MaxFloat32, true
+	}
+
+	return reflect.Value{}, err
+}
+
+// funcArgValue converts the parsed value v to typ, a nil value results in the zero value
+func funcArgValue(v interface{}, typ reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(typ), nil
+	}
+
+	switch {
+	case typ.Kind() == reflect.Slice:
+		vals, ok := v.([]interface{})
+		if !ok {
+			vals = []interface{}{v}
+		}
+
+		out := reflect.MakeSlice(typ, len(vals), len(vals))
+		for i, val := range vals {
+			elem, err := funcArgValue(val, typ.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+	case typ.Kind() == reflect.Ptr && typ.Elem().Kind() != reflect.Struct:
+		elem, err := funcArgValue(v, typ.Elem())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ptr := reflect.New(typ.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	}
+
+	if match, ok := v.(*AdvUserMatch); ok {
+		switch typ {
+		case memberStateType:
+			return reflect.ValueOf(match.Member), nil
+		case userType:
+			return reflect.ValueOf(match.User), nil
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(typ) {
+		return rv, nil
+	}
+
+	if isNumberKind(rv.Kind()) && isNumberKind(typ.Kind()) {
+		return convertNumber(rv, typ)
+	}
+
+	return reflect.Value{}, errors.Errorf("can't use a %T as a %s", v, typ)
+}
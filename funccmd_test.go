@@ -0,0 +1,118 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func runFuncCmd(t *testing.T, cmd *FuncCmd, input string, msg *discordgo.Message) (interface{}, error) {
+	defs, required, combos := cmd.ArgDefs(nil)
+	data := &Data{MsgStrippedPrefix: input, Msg: msg}
+	err := ParseArgDefs(defs, required, combos, data, SplitArgs(input))
+	if !assert.NoError(t, err) {
+		return nil, err
+	}
+
+	return cmd.Run(data)
+}
+
+func TestFuncCmd(t *testing.T) {
+	cmd, err := NewFuncCmd(func(data *Data, user *discordgo.User, days int64, reason string) (interface{}, error) {
+		return user.Username + " " + strconv.FormatInt(days, 10) + " " + reason, nil
+	}, &FuncArg{Name: "User"}, &FuncArg{Name: "Days", Help: "How many days"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defs, required, _ := cmd.ArgDefs(nil)
+	assert.Equal(t, 3, required)
+	assert.Equal(t, "User", defs[0].Name)
+	assert.Equal(t, "How many days", defs[1].Help)
+	assert.Equal(t, "Arg3", defs[2].Name)
+	assert.Equal(t, Remainder, defs[2].Type)
+
+	msg := &discordgo.Message{Mentions: []*discordgo.User{{ID: 1, Username: "bob"}}}
+	out, err := runFuncCmd(t, cmd, "<@1> 7 being rude", msg)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "bob 7 being rude", out)
+	}
+}
+
+func TestFuncCmdOptionalAndVariadic(t *testing.T) {
+	cmd, err := NewFuncCmd(func(limit *int, ids ...int64) (string, error) {
+		if limit == nil {
+			return "no limit", nil
+		}
+		return strconv.Itoa(*limit) + " " + strconv.Itoa(len(ids)), nil
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, required, _ := cmd.ArgDefs(nil)
+	assert.Equal(t, 0, required)
+
+	out, err := runFuncCmd(t, cmd, "5 1 2 3", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "5 3", out)
+	}
+
+	out, err = runFuncCmd(t, cmd, "", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "no limit", out)
+	}
+}
+
+func TestFuncCmdErrors(t *testing.T) {
+	_, err := NewFuncCmd("hello")
+	assert.Error(t, err)
+
+	_, err = NewFuncCmd(func(a int) error { return nil })
+	assert.Error(t, err, "wrong return values")
+
+	_, err = NewFuncCmd(func(a *int, b int) (interface{}, error) { return nil, nil })
+	assert.Error(t, err, "required after optional")
+
+	_, err = NewFuncCmd(func(a struct{}) (interface{}, error) { return nil, nil })
+	assert.Error(t, err, "no arg type")
+
+	_, err = NewFuncCmd(func(a int64) (interface{}, error) { return nil, nil }, &FuncArg{Type: String})
+	assert.Error(t, err, "type mismatch")
+}
+
+func TestFuncCmdNumberRange(t *testing.T) {
+	cmd, err := NewFuncCmd(func(small int32, unsigned uint8, f float32) (interface{}, error) {
+		return strconv.Itoa(int(small)) + " " + strconv.Itoa(int(unsigned)), nil
+	}, &FuncArg{Name: "Small"}, &FuncArg{Name: "Unsigned", Type: Int}, &FuncArg{Name: "F"})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	out, err := runFuncCmd(t, cmd, "-5 255 1.5", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "-5 255", out)
+	}
+
+	cases := []struct {
+		input string
+		err   string
+	}{
+		{"3000000000 1 1", "Small is too big (has to be within -2147483648 - 2147483647)"},
+		{"-3000000000 1 1", "Small is too small (has to be within -2147483648 - 2147483647)"},
+		{"1 -1 1", "Unsigned is too small (has to be within 0 - 255)"},
+		{"1 256 1", "Unsigned is too big (has to be within 0 - 255)"},
+		{"1 1 1e39", "F is too big (has to be within -340282346638528859811704183484516925440.000000 - 340282346638528859811704183484516925440.000000)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			_, err := runFuncCmd(t, cmd, c.input, nil)
+			if assert.Error(t, err) {
+				assert.Equal(t, c.err, err.Error())
+				assert.True(t, IsUserError(err), "Should be a user error")
+			}
+		})
+	}
+}
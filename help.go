@@ -3,6 +3,7 @@ package dcmd
 import (
 	"fmt"
 	"github.com/jonas747/discordgo"
+	"strings"
 )
 
 // HelpFormatter is a interface for help formatters, for an example see StdHelpFormatter
@@ -61,7 +62,7 @@ func (s *SortedCommandSet) Emoji() string {
 func SortCommands(closestGroupContainer *Container, cmdContainer *Container) []*SortedCommandSet {
 	containers := make([]*SortedCommandSet, 0)
 
-	for _, cmd := range cmdContainer.Commands {
+	for _, cmd := range cmdContainer.ListCommands() {
 		if cmd.Trigger.HideFromHelp {
 			continue
 		}
@@ -255,12 +256,57 @@ func (s *StdHelpFormatter) Switches(cmd Cmd) (str string) {
 	switches := cast.Switches()
 
 	for _, sw := range switches {
-		str += "[-" + sw.Switch + " " + s.ArgDef(sw) + "]\n"
+		if sw.Required {
+			str += "<" + s.SwitchNames(sw) + " " + s.ArgDef(sw) + ">"
+		} else {
+			str += "[" + s.SwitchNames(sw) + " " + s.ArgDef(sw) + "]"
+		}
+
+		str += s.SwitchRelations(sw, switches) + "\n"
 	}
 
 	return
 }
 
+// SwitchRelations returns a description of the switches this one conflicts with or requires, e.g " (not with -kick, requires -ban)"
+func (s *StdHelpFormatter) SwitchRelations(sw *ArgDef, switches []*ArgDef) string {
+	relations := make([]string, 0)
+	if sw.ExclusiveGroup != "" {
+		for _, v := range switches {
+			if v != sw && v.ExclusiveGroup == sw.ExclusiveGroup {
+				relations = append(relations, "not with "+s.SwitchNames(v))
+			}
+		}
+	}
+
+	for _, req := range sw.Requires {
+		if v := FindSwitch(switches, req); v != nil {
+			relations = append(relations, "requires "+s.SwitchNames(v))
+		}
+	}
+
+	if len(relations) < 1 {
+		return ""
+	}
+
+	return " (" + strings.Join(relations, ", ") + ")"
+}
+
+// SwitchNames returns the switch and its aliases, e.g "-user/-u"
+// Like in the errors the names are always shown with a single dash, even though "--user" is also accepted
+func (s *StdHelpFormatter) SwitchNames(sw *ArgDef) string {
+	str := ""
+	for _, name := range append([]string{sw.Switch}, sw.SwitchAliases...) {
+		if str != "" {
+			str += "/"
+		}
+
+		str += "-" + name
+	}
+
+	return str
+}
+
 func (s *StdHelpFormatter) ArgDefs(cmd *RegisteredCommand, data *Data) (str string) {
 	cast, ok := cmd.Command.(CmdWithArgDefs)
 	if !ok {
@@ -312,11 +358,24 @@ func (s *StdHelpFormatter) ArgDef(arg *ArgDef) (str string) {
 		tName = arg.Type.HelpName()
 	}
 
-	str = fmt.Sprintf("%s:%s", arg.Name, tName)
+	name := arg.Name
+	if arg.Variadic {
+		name += "..."
+	}
+
+	str = fmt.Sprintf("%s:%s", name, tName)
 	if arg.Help != "" {
 		str += " - " + arg.Help
 	}
 
+	if arg.DefaultHelp != "" {
+		str += " (default: " + arg.DefaultHelp + ")"
+	}
+
+	if arg.FromReply != ReplyNone {
+		str += " (or reply to a message)"
+	}
+
 	return
 }
 
@@ -346,7 +405,7 @@ func (h *StdHelpCommand) Descriptions(data *Data) (string, string) {
 
 func (h *StdHelpCommand) ArgDefs(data *Data) (args []*ArgDef, required int, combos [][]int) {
 	return []*ArgDef{
-		{Name: "Command", Type: String},
+		{Name: "Command", Type: Remainder},
 	}, 0, nil
 }
 
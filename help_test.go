@@ -0,0 +1,22 @@
+package dcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSwitchNames(t *testing.T) {
+	switches := []*ArgDef{
+		{Switch: "ban", SwitchAliases: []string{"b"}, ExclusiveGroup: "action"},
+		{Switch: "kick", ExclusiveGroup: "action"},
+		{Switch: "silent", Requires: []string{"b"}},
+	}
+
+	hf := &StdHelpFormatter{}
+	assert.Equal(t, "-ban/-b", hf.SwitchNames(switches[0]))
+	assert.Equal(t, " (not with -kick)", hf.SwitchRelations(switches[0], switches))
+	assert.Equal(t, " (requires -ban/-b)", hf.SwitchRelations(switches[2], switches))
+
+	err := &MissingSwitchValue{Switch: "days", TypeName: "Whole number"}
+	assert.Equal(t, "Switch -days requires a value (Whole number)", err.Error())
+}
@@ -0,0 +1,484 @@
+package dcmd
+
+import (
+	"bytes"
+	"container/heap"
+	"github.com/jonas747/dstate"
+	"github.com/jonas747/dutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// How well a member matched a search, higher is better
+const (
+	MemberMatchFuzzy = iota + 1
+	MemberMatchSubstring
+	MemberMatchPrefix
+	MemberMatchExact
+)
+
+// MaxMemberSuggestions is the max number of members listed when a search was ambiguous
+var MaxMemberSuggestions = 5
+
+// MemberSearchNames appends the names a member can be found by, in addition to the username, to names and returns it.
+// By default that's only the nickname. Global display names are not searched by default as dstate doesn't track them,
+// replace this to search them (or any other names) if your state does.
+var MemberSearchNames = func(ms *dstate.MemberState, names []string) []string {
+	if ms.Nick == "" {
+		return names
+	}
+
+	return append(names, ms.Nick)
+}
+
+// MemberMatch is a member found by SearchMembers
+type MemberMatch struct {
+	Member *dstate.MemberState
+
+	// One of the MemberMatch constants
+	Rank int
+
+	// The name that matched
+	Name string
+
+	// The position of the member among the recent speakers in the channel (0 being the latest one), or -1 if they didn't speak recently
+	Recent int
+}
+
+var discriminatorRegex = regexp.MustCompile(`^(.+)#(\d{4})$`)
+
+// FindDiscordMemberByName searches the guild for a member by name, see FindMemberByName
+func FindDiscordMemberByName(gs *dstate.GuildState, str string) (*dstate.MemberState, error) {
+	return FindMemberByName(gs, str, 0)
+}
+
+// FindMemberByName searches the guild for a member by username, nickname (see MemberSearchNames) or "username#1234".
+// A member is only returned on a exact (case and confusable insensitive) match, if multiple members match exactly
+// and only one of them recently spoke in the channel then that one is returned, otherwise a error listing the best matches is returned.
+// channelID can be 0 to disable the recency bias.
+func FindMemberByName(gs *dstate.GuildState, str string, channelID int64) (*dstate.MemberState, error) {
+	// Atleast 2 are needed to tell if there's more than one exact match
+	limit := MaxMemberSuggestions
+	if limit < 2 {
+		limit = 2
+	}
+
+	matches := SearchMembers(gs, str, channelID, limit)
+	if len(matches) < 1 {
+		return nil, &UserNotFound{dutil.EscapeEveryoneMention(str)}
+	}
+
+	exact := 0
+	recentExact := 0
+	var recentMatch *MemberMatch
+	for _, v := range matches {
+		if v.Rank != MemberMatchExact {
+			break
+		}
+
+		exact++
+		if v.Recent != -1 {
+			recentExact++
+			recentMatch = v
+		}
+	}
+
+	if exact == 1 {
+		return matches[0].Member, nil
+	}
+
+	if exact > 1 && recentExact == 1 {
+		return recentMatch.Member, nil
+	}
+
+	if len(matches) > MaxMemberSuggestions {
+		matches = matches[:MaxMemberSuggestions]
+	}
+
+	out := ""
+	for _, v := range matches {
+		if out != "" {
+			out += ", "
+		}
+
+		out += "`" + memberTag(v.Member) + "`"
+	}
+
+	if exact > 1 {
+		return nil, NewSimpleUserError("Too many users with that name, " + out + ". Please re-run the command with a narrower search, mention or ID.")
+	}
+
+	return nil, NewSimpleUserError("Did you mean one of these? " + out + ". Please re-run the command with a narrower search, mention or ID")
+}
+
+// SearchMembers returns the best limit members matching str, ranked by how well they matched and then by how recently they spoke in the channel (if channelID is not 0).
+// str can also be "username#1234" to only match members with that discriminator.
+// The returned members are copies.
+func SearchMembers(gs *dstate.GuildState, str string, channelID int64, limit int) []*MemberMatch {
+	if limit < 1 {
+		return nil
+	}
+
+	discrim := int32(-1)
+	if m := discriminatorRegex.FindStringSubmatch(str); m != nil {
+		parsed, _ := strconv.ParseInt(m[2], 10, 32)
+		discrim = int32(parsed)
+		str = m[1]
+	}
+
+	search := NormalizeName(str)
+	if search == "" {
+		return nil
+	}
+	ranker := newNameRanker(search)
+
+	gs.RLock()
+	defer gs.RUnlock()
+
+	recent := recentSpeakers(gs, channelID)
+
+	// Keep the best matches in a heap with the worst one on top, so it's the one replaced by a better match
+	best := make(memberMatchHeap, 0, limit)
+	var names []string
+	for _, v := range gs.Members {
+		if v == nil || v.Username == "" {
+			continue
+		}
+
+		var match MemberMatch
+		if discrim != -1 {
+			// The discriminator belongs to the username, so don't look at the other names
+			if v.Discriminator != discrim {
+				continue
+			}
+			match = ranker.rank(v.Username)
+		} else {
+			match = ranker.rank(v.Username)
+			names = MemberSearchNames(v, names[:0])
+			for _, name := range names {
+				if m := ranker.rank(name); m.Rank > match.Rank {
+					match = m
+				}
+			}
+		}
+
+		if match.Rank == 0 {
+			continue
+		}
+
+		// Copied after the search, so only the returned members are copied
+		match.Member = v
+		match.Recent = -1
+		if pos, ok := recent[v.ID]; ok {
+			match.Recent = pos
+		}
+
+		if len(best) < limit {
+			kept := match
+			heap.Push(&best, &kept)
+		} else if betterMemberMatch(&match, best[0]) {
+			// Reuse the worst one
+			*best[0] = match
+			heap.Fix(&best, 0)
+		}
+	}
+
+	matches := []*MemberMatch(best)
+	sort.Slice(matches, func(i, j int) bool {
+		return betterMemberMatch(matches[i], matches[j])
+	})
+
+	for _, v := range matches {
+		v.Member = v.Member.Copy()
+	}
+
+	return matches
+}
+
+// betterMemberMatch returns true if a should be ranked before b
+func betterMemberMatch(a, b *MemberMatch) bool {
+	if a.Rank != b.Rank {
+		return a.Rank > b.Rank
+	}
+
+	if a.Recent != b.Recent {
+		if a.Recent == -1 || b.Recent == -1 {
+			return b.Recent == -1
+		}
+		return a.Recent < b.Recent
+	}
+
+	if len(a.Name) != len(b.Name) {
+		return len(a.Name) < len(b.Name)
+	}
+
+	return a.Member.ID < b.Member.ID
+}
+
+// memberMatchHeap is a heap.Interface with the worst match on top
+type memberMatchHeap []*MemberMatch
+
+func (h memberMatchHeap) Len() int            { return len(h) }
+func (h memberMatchHeap) Less(i, j int) bool  { return betterMemberMatch(h[j], h[i]) }
+func (h memberMatchHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *memberMatchHeap) Push(x interface{}) { *h = append(*h, x.(*MemberMatch)) }
+func (h *memberMatchHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// recentSpeakers returns the position of the authors of the messages in the channel, 0 being the author of the latest message
+// gs has to be locked
+func recentSpeakers(gs *dstate.GuildState, channelID int64) map[int64]int {
+	if channelID == 0 {
+		return nil
+	}
+
+	cs := gs.Channel(false, channelID)
+	if cs == nil {
+		return nil
+	}
+
+	out := make(map[int64]int)
+	for i := len(cs.Messages) - 1; i >= 0; i-- {
+		msg := cs.Messages[i]
+		if msg == nil || msg.Message == nil || msg.Message.Author == nil {
+			continue
+		}
+
+		if _, ok := out[msg.Message.Author.ID]; !ok {
+			out[msg.Message.Author.ID] = len(out)
+		}
+	}
+
+	return out
+}
+
+// nameRanker ranks names by how well the normalized search matches them,
+// the buffers are reused for every name so that it doesn't allocate for every member
+type nameRanker struct {
+	search      string
+	searchBytes []byte
+	searchRunes []rune
+
+	normalized []byte
+	nameRunes  []rune
+	prev, cur  []int
+}
+
+func newNameRanker(search string) *nameRanker {
+	return &nameRanker{search: search, searchBytes: []byte(search), searchRunes: []rune(search)}
+}
+
+// rank returns how well the search matches name, the Rank is 0 if it didn't match
+func (r *nameRanker) rank(name string) MemberMatch {
+	if name == "" {
+		return MemberMatch{}
+	}
+
+	r.normalized = appendNormalizedName(r.normalized[:0], name)
+	normalized := r.normalized
+
+	rank := 0
+	switch {
+	case string(normalized) == r.search:
+		rank = MemberMatchExact
+	case bytes.HasPrefix(normalized, r.searchBytes):
+		rank = MemberMatchPrefix
+	case bytes.Contains(normalized, r.searchBytes):
+		rank = MemberMatchSubstring
+	case r.fuzzyMatch(normalized):
+		rank = MemberMatchFuzzy
+	default:
+		return MemberMatch{}
+	}
+
+	return MemberMatch{Name: name, Rank: rank}
+}
+
+// fuzzyMatch returns true if the search is close to name, either by it being a abbreviation of name starting with the same rune (e.g "jnas" for "jonas")
+// or by a small edit distance to name or a prefix of it, to allow for typos
+func (r *nameRanker) fuzzyMatch(name []byte) bool {
+	s := r.searchRunes
+	if len(s) < 3 {
+		return false
+	}
+
+	// Abbreviation, all the runes of search appear in name in order
+	pos := 0
+	for i, c := range string(name) {
+		if pos < len(s) && c == s[pos] {
+			pos++
+		} else if i == 0 {
+			// has to start with the same rune
+			break
+		}
+	}
+	if pos == len(s) {
+		return true
+	}
+
+	maxDist := 1
+	if len(s) >= 8 {
+		maxDist = 2
+	}
+
+	// The distance is atleast the difference in length, names longer than search are compared by their prefix
+	if len(s)-utf8.RuneCount(name) > maxDist {
+		return false
+	}
+
+	r.nameRunes = r.nameRunes[:0]
+	for _, c := range string(name) {
+		if len(r.nameRunes) == len(s) {
+			// Allow typos in a prefix
+			break
+		}
+		r.nameRunes = append(r.nameRunes, c)
+	}
+
+	return r.editDistance(s, r.nameRunes) <= maxDist
+}
+
+// editDistance returns the levenshtein distance between a and b
+func (r *nameRanker) editDistance(a, b []rune) int {
+	if cap(r.prev) < len(b)+1 {
+		r.prev = make([]int, len(b)+1)
+		r.cur = make([]int, len(b)+1)
+	}
+	prev, cur := r.prev[:len(b)+1], r.cur[:len(b)+1]
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// NormalizeName case folds name and replaces confusable runes (such as cyrillic and fullwidth letters) with their ascii lookalikes,
+// and removes invisible runes, so that names can be compared the way they look
+func NormalizeName(name string) string {
+	return string(appendNormalizedName(make([]byte, 0, len(name)), name))
+}
+
+// appendNormalizedName appends the normalized name to dst, see NormalizeName
+func appendNormalizedName(dst []byte, name string) []byte {
+	name = strings.TrimSpace(name)
+	if isASCII(name) {
+		// None of the confusables or invisible runes are ascii, so only the case has to be folded
+		for i := 0; i < len(name); i++ {
+			c := name[i]
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			dst = append(dst, c)
+		}
+
+		return dst
+	}
+
+	for _, r := range name {
+		switch {
+		case r == 0x200B || r == 0x200C || r == 0x200D || r == 0x2060 || r == 0xFEFF:
+			// zero width characters
+			continue
+		case r >= 0xFF01 && r <= 0xFF5E:
+			// fullwidth forms
+			r -= 0xFEE0
+		}
+
+		if c, ok := confusables[r]; ok {
+			r = c
+		}
+
+		dst = utf8.AppendRune(dst, unicode.ToLower(foldRune(r)))
+	}
+
+	return dst
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// foldRune returns the smallest rune in the case folding orbit of r, so that all the case variants of a rune map to the same one
+func foldRune(r rune) rune {
+	min := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if f < min {
+			min = f
+		}
+	}
+
+	return min
+}
+
+// confusables maps common lookalikes of latin letters to them
+var confusables = map[rune]rune{
+	// cyrillic
+	'а': 'a', 'А': 'A', 'в': 'B', 'В': 'B', 'е': 'e', 'Е': 'E', 'ё': 'e', 'к': 'k', 'К': 'K', 'м': 'M', 'М': 'M',
+	'н': 'H', 'Н': 'H', 'о': 'o', 'О': 'O', 'р': 'p', 'Р': 'P', 'с': 'c', 'С': 'C', 'т': 'T', 'Т': 'T',
+	'у': 'y', 'У': 'Y', 'х': 'x', 'Х': 'X', 'і': 'i', 'І': 'I', 'ј': 'j', 'Ј': 'J', 'ѕ': 's', 'Ѕ': 'S', 'ԁ': 'd',
+	// greek
+	'α': 'a', 'Α': 'A', 'Β': 'B', 'ε': 'e', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'ι': 'i', 'Ι': 'I', 'κ': 'k', 'Κ': 'K',
+	'Μ': 'M', 'ν': 'v', 'Ν': 'N', 'ο': 'o', 'Ο': 'O', 'ρ': 'p', 'Ρ': 'P', 'τ': 't', 'Τ': 'T', 'υ': 'u', 'Υ': 'Y',
+	'χ': 'x', 'Χ': 'X',
+}
+
+// memberTag returns "username#1234", or just the username if it has no discriminator
+func memberTag(ms *dstate.MemberState) string {
+	if ms.Discriminator == 0 {
+		return ms.Username
+	}
+
+	return ms.Username + "#" + leftPad(strconv.Itoa(int(ms.Discriminator)), 4)
+}
+
+func leftPad(s string, n int) string {
+	if len(s) >= n {
+		return s
+	}
+
+	return strings.Repeat("0", n-len(s)) + s
+}
+
+// searchChannelID returns the channel to bias member searches towards, or 0
+func searchChannelID(data *Data) int64 {
+	if data.CS == nil {
+		return 0
+	}
+
+	return data.CS.ID
+}
@@ -0,0 +1,130 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func testSearchGuild() *dstate.GuildState {
+	gs := &dstate.GuildState{
+		Members: map[int64]*dstate.MemberState{
+			1: {ID: 1, Username: "jonas", Discriminator: 1234},
+			2: {ID: 2, Username: "Jonas", Discriminator: 5},
+			3: {ID: 3, Username: "jonathan", Nick: "John"},
+			4: {ID: 4, Username: "someone", Nick: "mr jonas man"},
+			5: {ID: 5, Username: "ⅿanager", Nick: "Вob"},
+			6: {ID: 6, Username: "alexander"},
+		},
+		Channels: map[int64]*dstate.ChannelState{},
+	}
+
+	gs.Channels[10] = &dstate.ChannelState{ID: 10, Messages: []*dstate.MessageState{
+		{Message: &discordgo.Message{Author: &discordgo.User{ID: 2}}},
+		{Message: &discordgo.Message{Author: &discordgo.User{ID: 6}}},
+	}}
+
+	return gs
+}
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "bob", NormalizeName("Вob"))
+	assert.Equal(t, "jonas", NormalizeName("ＪＯＮＡＳ"))
+	assert.Equal(t, "jonas", NormalizeName("jo​nas"))
+	assert.Equal(t, "straße", NormalizeName("STRAßE"))
+	assert.Equal(t, "mr jonas", NormalizeName(" Mr JONAS "))
+	assert.Equal(t, "kelvin", NormalizeName("\u212Aelvin"))
+}
+
+func TestSearchMembersRanking(t *testing.T) {
+	gs := testSearchGuild()
+
+	matches := SearchMembers(gs, "jon", 0, 10)
+	ids := make([]int64, len(matches))
+	for i, v := range matches {
+		ids[i] = v.Member.ID
+	}
+
+	// prefix matches on shorter names first, then the substring match on the nickname
+	assert.Equal(t, []int64{1, 2, 3, 4}, ids)
+	assert.Equal(t, MemberMatchPrefix, matches[0].Rank)
+	assert.Equal(t, "jonathan", matches[2].Name)
+	assert.Equal(t, MemberMatchSubstring, matches[3].Rank)
+
+	matches = SearchMembers(gs, "alxander", 0, 10)
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, MemberMatchFuzzy, matches[0].Rank)
+	}
+}
+
+func TestFindMemberByName(t *testing.T) {
+	gs := testSearchGuild()
+
+	_, err := FindMemberByName(gs, "jonas", 0)
+	assert.Error(t, err, "ambiguous without recency")
+
+	m, err := FindMemberByName(gs, "jonas", 10)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(2), m.ID, "should prefer the recent speaker")
+	}
+
+	m, err = FindMemberByName(gs, "jonas#1234", 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(1), m.ID)
+	}
+
+	m, err = FindMemberByName(gs, "bob", 0)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(5), m.ID, "should match the confusable nickname")
+	}
+
+	_, err = FindMemberByName(gs, "nobody here", 0)
+	assert.IsType(t, &UserNotFound{}, err)
+
+	_, err = FindMemberByName(gs, "alex", 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "Did you mean one of these? `alexander`")
+	}
+}
+
+func TestSearchMembersLimit(t *testing.T) {
+	gs := testSearchGuild()
+	for i := int64(100); i < 300; i++ {
+		gs.Members[i] = &dstate.MemberState{ID: i, Username: "jon" + strconv.FormatInt(i, 10)}
+	}
+
+	all := SearchMembers(gs, "jon", 10, 1000)
+	assert.Len(t, all, 204)
+
+	limited := SearchMembers(gs, "jon", 10, 5)
+	if assert.Len(t, limited, 5) {
+		for i, v := range limited {
+			assert.Equal(t, all[i].Member.ID, v.Member.ID)
+			assert.True(t, v.Member != gs.Members[v.Member.ID], "Should return a copy")
+		}
+	}
+
+	assert.Len(t, SearchMembers(gs, "jon", 0, 0), 0)
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	assert.True(t, newNameRanker("jns").fuzzyMatch([]byte("jonas")), "abbreviation")
+	assert.False(t, newNameRanker("oas").fuzzyMatch([]byte("jonas")), "abbreviation not starting with the same rune")
+	assert.True(t, newNameRanker("jomas").fuzzyMatch([]byte("jonas")), "typo")
+	assert.True(t, newNameRanker("jomas").fuzzyMatch([]byte("jonasxyz")), "typo in prefix")
+	assert.False(t, newNameRanker("jonathan").fuzzyMatch([]byte("jon")), "too short")
+}
+
+func TestSearchMembersAllocs(t *testing.T) {
+	gs := &dstate.GuildState{Members: make(map[int64]*dstate.MemberState)}
+	for i := int64(0); i < 1000; i++ {
+		gs.Members[i] = &dstate.MemberState{ID: i, Username: "User" + strconv.FormatInt(i, 10), Nick: "Nick"}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		SearchMembers(gs, "zzzz", 0, 5)
+	})
+	assert.True(t, allocs < 50, "Should not allocate for every member, allocs: %v", allocs)
+}
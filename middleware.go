@@ -0,0 +1,308 @@
+package dcmd
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"sync/atomic"
+)
+
+// Middleware is a named middleware, see Container.AddMiddleware
+type Middleware struct {
+	// Name is used to remove the middleware and in the Before and After constraints of other middlewares,
+	// it has to be unique within the container. It can be empty for middlewares that are never referred to.
+	Name string
+	Func MiddleWareFunc
+
+	// Middlewares with a lower priority are ran first, middlewares with the same priority are ran in the order they were added.
+	// A middleware that others have to run after is ran as early as the lowest priority of those, so that they're not delayed by it,
+	// e.g a middleware with priority 10 that one with priority -20 runs after is ran as if it had priority -20
+	Priority int
+
+	// The names of the middlewares in the same container that this one has to run before or after, these take precedence over the Priority.
+	// Names of middlewares that have not been added are ignored
+	Before []string
+	After  []string
+}
+
+func (m *Middleware) copy() *Middleware {
+	cop := *m
+	cop.Before = append([]string(nil), m.Before...)
+	cop.After = append([]string(nil), m.After...)
+	return &cop
+}
+
+func (m *Middleware) String() string {
+	if m.Name == "" {
+		return "<unnamed>"
+	}
+
+	return m.Name
+}
+
+// AddMiddleware adds a named middleware, returning a error if the name is already used or if the Before and After constraints can't be satisfied.
+// The middleware is copied, so changing it after adding it has no effect
+func (c *Container) AddMiddleware(mw *Middleware) error {
+	if mw.Func == nil {
+		return errors.New("Middleware " + mw.String() + " has no Func")
+	}
+
+	mw = mw.copy()
+
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if mw.Name != "" {
+		for _, v := range s.addedMiddlewares {
+			if v.Name == mw.Name {
+				return errors.New("Middleware " + mw.Name + " already added")
+			}
+		}
+	}
+
+	added := append(s.addedMiddlewares[:len(s.addedMiddlewares):len(s.addedMiddlewares)], mw)
+	return s.setMiddlewares(added)
+}
+
+// RemoveNamedMiddleware removes the middleware with the name, returning false if it was not found
+func (c *Container) RemoveNamedMiddleware(name string) bool {
+	s := c.state()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, v := range s.addedMiddlewares {
+		if v.Name == name {
+			s.removeMiddleware(i)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middlewares returns copies of the middlewares of this container in the order they're ran in
+func (c *Container) Middlewares() []*Middleware {
+	s := c.state()
+	s.mu.RLock()
+	middlewares := s.middlewares
+	s.mu.RUnlock()
+
+	cop := make([]*Middleware, len(middlewares))
+	for i, v := range middlewares {
+		cop[i] = v.copy()
+	}
+
+	return cop
+}
+
+// removeMiddleware removes the i'th added middleware, s.mu has to be locked
+func (s *containerState) removeMiddleware(i int) {
+	added := make([]*Middleware, 0, len(s.addedMiddlewares)-1)
+	added = append(added, s.addedMiddlewares[:i]...)
+	added = append(added, s.addedMiddlewares[i+1:]...)
+
+	// The remaining middlewares were ordered before, and removing one only removes constraints,
+	// as the Before and After names of middlewares that are not added are ignored. So they can always be ordered
+	s.setMiddlewares(added)
+}
+
+// setMiddlewares orders and sets the middlewares, s.mu has to be locked
+func (s *containerState) setMiddlewares(added []*Middleware) error {
+	ordered, err := orderMiddlewares(added)
+	if err != nil {
+		return err
+	}
+
+	s.addedMiddlewares = added
+	s.middlewares = ordered
+
+	// Rebuild the cached middleware chains that include this container
+	atomic.AddUint64(&s.generation, 1)
+	return nil
+}
+
+// orderMiddlewares sorts the middlewares so that the Before and After constraints are satisfied,
+// otherwise ordering them by priority (see Middleware.Priority) and then the order they were added in
+func orderMiddlewares(added []*Middleware) ([]*Middleware, error) {
+	byName := make(map[string]int)
+	for i, v := range added {
+		if v.Name != "" {
+			byName[v.Name] = i
+		}
+	}
+
+	// runsAfter[i] are the middlewares that has to run before i
+	runsAfter := make([][]int, len(added))
+	priorities := make([]int, len(added))
+	for i, v := range added {
+		for _, name := range v.Before {
+			if j, ok := byName[name]; ok {
+				runsAfter[j] = append(runsAfter[j], i)
+			}
+		}
+
+		for _, name := range v.After {
+			if j, ok := byName[name]; ok {
+				runsAfter[i] = append(runsAfter[i], j)
+			}
+		}
+
+		priorities[i] = v.Priority
+	}
+
+	// Middlewares are ran as early as the ones that have to run after them, so that a low priority middleware is not delayed
+	// by a higher priority one it has to run after. The priorities only decrease, so this terminates even with cycles
+	for changed := true; changed; {
+		changed = false
+		for i := range added {
+			for _, j := range runsAfter[i] {
+				if priorities[i] < priorities[j] {
+					priorities[j] = priorities[i]
+					changed = true
+				}
+			}
+		}
+	}
+
+	ordered := make([]*Middleware, 0, len(added))
+	done := make([]bool, len(added))
+	for len(ordered) < len(added) {
+		// Pick the middleware with the lowest priority, that has no middlewares left that has to run before it
+		next := -1
+		for i := range added {
+			if done[i] || (next != -1 && priorities[next] <= priorities[i]) {
+				continue
+			}
+
+			ready := true
+			for _, j := range runsAfter[i] {
+				if !done[j] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				next = i
+			}
+		}
+
+		if next == -1 {
+			cycle := cyclePath(runsAfter, done)
+			names := make([]string, len(cycle))
+			for i, v := range cycle {
+				names[i] = added[v].String()
+			}
+			return nil, errors.New("Middleware Before/After constraints have a cycle: " + strings.Join(names, " -> "))
+		}
+
+		done[next] = true
+		ordered = append(ordered, added[next])
+	}
+
+	return ordered, nil
+}
+
+// cyclePath returns a cycle among the middlewares that are not done, in the order they have to run in,
+// starting and ending with the same middleware. Every middleware that is not done has to have one that it runs after that is not done
+func cyclePath(runsAfter [][]int, done []bool) []int {
+	visited := make(map[int]int)
+	path := make([]int, 0)
+
+	current := -1
+	for i := range done {
+		if !done[i] {
+			current = i
+			break
+		}
+	}
+
+	// Walk backwards through the middlewares that have to run before the current one until one is seen again
+	for {
+		if start, ok := visited[current]; ok {
+			cycle := append(path[start:], current)
+			for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+				cycle[i], cycle[j] = cycle[j], cycle[i]
+			}
+			return cycle
+		}
+
+		visited[current] = len(path)
+		path = append(path, current)
+
+		for _, j := range runsAfter[current] {
+			if !done[j] {
+				current = j
+				break
+			}
+		}
+	}
+}
+
+// MiddlewareChainEntry is a middleware in the effective chain of a command, see Container.MiddlewareChain
+type MiddlewareChainEntry struct {
+	Middleware *Middleware
+
+	// The container the middleware was added to, nil for middlewares of the trigger
+	Container *Container
+}
+
+// MiddlewareChain is the effective middleware chain of a command, in the order the middlewares are ran
+type MiddlewareChain []*MiddlewareChainEntry
+
+func (m MiddlewareChain) String() string {
+	lines := make([]string, len(m))
+	for i, v := range m {
+		source := "trigger"
+		if v.Container != nil {
+			source = v.Container.FullName(false)
+			if source == "" {
+				source = "root"
+			}
+		}
+
+		lines[i] = fmt.Sprintf("%d. %s (%s)", i+1, v.Middleware, source)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// MiddlewareChain returns the middlewares that are ran for cmd, which can be in this container or any of its sub containers,
+// returning nil if the command was not found. The middlewares in it are copies
+func (c *Container) MiddlewareChain(cmd *RegisteredCommand) MiddlewareChain {
+	path := c.pathTo(cmd)
+	if path == nil {
+		return nil
+	}
+
+	chain := make(MiddlewareChain, 0)
+	for _, container := range path {
+		for _, mw := range container.Middlewares() {
+			chain = append(chain, &MiddlewareChainEntry{Middleware: mw, Container: container})
+		}
+	}
+
+	for _, mw := range cmd.Trigger.Middlewares() {
+		chain = append(chain, &MiddlewareChainEntry{Middleware: &Middleware{Func: mw}})
+	}
+
+	return chain
+}
+
+// pathTo returns the containers from this one to the one cmd is in, or nil if it's not found
+func (c *Container) pathTo(cmd *RegisteredCommand) []*Container {
+	for _, v := range c.ListCommands() {
+		if v == cmd {
+			return []*Container{c}
+		}
+
+		if sub, ok := v.Command.(*Container); ok {
+			if path := sub.pathTo(cmd); path != nil {
+				return append([]*Container{c}, path...)
+			}
+		}
+	}
+
+	return nil
+}
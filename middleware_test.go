@@ -1,6 +1,8 @@
 package dcmd
 
 import (
+	"github.com/stretchr/testify/assert"
+	"sync"
 	"testing"
 )
 
@@ -96,3 +98,226 @@ func TestMiddlewareOrder(t *testing.T) {
 	container.BuildMiddlewareChains(nil)
 	doTest()
 }
+
+func TestMiddlewareChainInvalidation(t *testing.T) {
+	container := &Container{}
+	container.AddCommand(&TestCommand{}, NewTrigger("test"))
+
+	run := func() {
+		container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+	}
+
+	// build the chain
+	run()
+
+	calls := 0
+	container.AddMidlewares(func(inner RunFunc) RunFunc {
+		return func(d *Data) (interface{}, error) {
+			calls++
+			return inner(d)
+		}
+	})
+
+	run()
+	if calls != 1 {
+		t.Error("Middleware added after the chain was built was not ran, calls:", calls)
+	}
+
+	container.ListCommands()[0].Trigger.SetMiddlewares(func(inner RunFunc) RunFunc {
+		return func(d *Data) (interface{}, error) {
+			calls += 10
+			return inner(d)
+		}
+	})
+
+	run()
+	if calls != 12 {
+		t.Error("Trigger middleware added after the chain was built was not ran, calls:", calls)
+	}
+}
+
+func TestMiddlewareChainConcurrentDispatch(t *testing.T) {
+	container := &Container{}
+	container.AddCommand(&TestCommand{}, NewTrigger("test"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				resp, _ := container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+				if resp != TestResponse {
+					t.Error("Response: ", resp, ", Expected: ", TestResponse)
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		InvalidateMiddlewareChains()
+	}
+
+	wg.Wait()
+}
+
+func recordingMiddleware(order *[]string, name string) MiddleWareFunc {
+	return func(inner RunFunc) RunFunc {
+		return func(d *Data) (interface{}, error) {
+			*order = append(*order, name)
+			return inner(d)
+		}
+	}
+}
+
+func TestNamedMiddlewareOrder(t *testing.T) {
+	container := &Container{}
+	cmd := container.AddCommand(&TestCommand{}, NewTrigger("test"))
+
+	var order []string
+	add := func(mw *Middleware) {
+		mw.Func = recordingMiddleware(&order, mw.Name)
+		if err := container.AddMiddleware(mw); err != nil {
+			t.Fatal("Failed adding middleware:", err)
+		}
+	}
+
+	add(&Middleware{Name: "log"})
+	add(&Middleware{Name: "ratelimit", Priority: 10})
+	add(&Middleware{Name: "perms", Priority: -10})
+	add(&Middleware{Name: "cooldown", Priority: -20, After: []string{"ratelimit"}})
+	add(&Middleware{Name: "auth", Priority: 20, Before: []string{"perms"}})
+	add(&Middleware{Name: "metrics", Before: []string{"missing"}})
+	container.AddMidlewares(recordingMiddleware(&order, ""))
+
+	run := func() []string {
+		order = nil
+		container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+		return order
+	}
+
+	// ratelimit and auth are ran as early as cooldown and perms that have to run after them
+	expected := []string{"ratelimit", "cooldown", "auth", "perms", "log", "metrics", ""}
+	assert.Equal(t, expected, run())
+
+	assert.True(t, container.RemoveNamedMiddleware("perms"))
+	assert.False(t, container.RemoveNamedMiddleware("perms"))
+	assert.Equal(t, []string{"ratelimit", "cooldown", "log", "metrics", "", "auth"}, run())
+
+	chain := container.MiddlewareChain(cmd)
+	if assert.Len(t, chain, 6) {
+		assert.Equal(t, "ratelimit", chain[0].Middleware.Name)
+		assert.Equal(t, container, chain[0].Container)
+	}
+}
+
+func TestMiddlewareCopied(t *testing.T) {
+	container := &Container{}
+	noop := func(inner RunFunc) RunFunc { return inner }
+
+	first := &Middleware{Name: "first", Func: noop, Priority: 10, After: []string{"second"}}
+	assert.NoError(t, container.AddMiddleware(first))
+	assert.NoError(t, container.AddMiddleware(&Middleware{Name: "second", Func: noop}))
+
+	// changing the middleware after adding it should not change the order
+	first.Priority = -10
+	first.After[0] = "missing"
+	names := func() []string {
+		var names []string
+		for _, v := range container.Middlewares() {
+			names = append(names, v.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"second", "first"}, names())
+
+	container.Middlewares()[0].Name = "changed"
+	container.MiddlewareChain(container.AddCommand(&TestCommand{}, NewTrigger("test")))[1].Middleware.After[0] = "changed"
+	assert.Equal(t, []string{"second", "first"}, names())
+	assert.Equal(t, []string{"second"}, container.Middlewares()[1].After)
+}
+
+func TestAddMiddlewareErrors(t *testing.T) {
+	container := &Container{}
+	noop := func(inner RunFunc) RunFunc { return inner }
+
+	assert.NoError(t, container.AddMiddleware(&Middleware{Name: "a", Func: noop, Before: []string{"b"}}))
+	assert.Error(t, container.AddMiddleware(&Middleware{Name: "a", Func: noop}), "Duplicate name")
+	err := container.AddMiddleware(&Middleware{Name: "b", Func: noop, Before: []string{"a"}})
+	if assert.Error(t, err, "Cycle") {
+		assert.Equal(t, "Middleware Before/After constraints have a cycle: a -> b -> a", err.Error())
+	}
+	assert.Error(t, container.AddMiddleware(&Middleware{Name: "c"}), "No func")
+
+	// the failed middlewares should not have been added
+	assert.Len(t, container.Middlewares(), 1)
+	assert.NoError(t, container.AddMiddleware(&Middleware{Name: "b", Func: noop}))
+}
+
+func TestMiddlewareCyclePath(t *testing.T) {
+	container := &Container{}
+	noop := func(inner RunFunc) RunFunc { return inner }
+
+	// Only the middlewares in the cycle should be reported, not the ones waiting on it
+	assert.NoError(t, container.AddMiddleware(&Middleware{Name: "log", Func: noop, After: []string{"x"}}))
+	assert.NoError(t, container.AddMiddleware(&Middleware{Name: "x", Func: noop, After: []string{"z"}}))
+	assert.NoError(t, container.AddMiddleware(&Middleware{Name: "y", Func: noop, After: []string{"x"}}))
+	err := container.AddMiddleware(&Middleware{Name: "z", Func: noop, After: []string{"y"}})
+	if assert.Error(t, err) {
+		assert.Equal(t, "Middleware Before/After constraints have a cycle: x -> y -> z -> x", err.Error())
+	}
+}
+
+func TestMiddlewareChainString(t *testing.T) {
+	container := &Container{}
+	noop := func(inner RunFunc) RunFunc { return inner }
+	container.AddMiddleware(&Middleware{Name: "log", Func: noop})
+
+	sub := container.Sub("sub")
+	sub.AddMidlewares(noop)
+	cmd := sub.AddCommand(&TestCommand{}, NewTrigger("test").SetMiddlewares(noop))
+
+	chain := container.MiddlewareChain(cmd)
+	assert.Equal(t, "1. log (root)\n2. <unnamed> (sub)\n3. <unnamed> (trigger)", chain.String())
+
+	assert.Nil(t, container.MiddlewareChain(&RegisteredCommand{}), "Unknown command")
+}
+
+func TestMiddlewareChainInvalidationScope(t *testing.T) {
+	container := &Container{}
+	cmd := container.AddCommand(&TestCommand{}, NewTrigger("test"))
+	other := &Container{}
+
+	container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+	built := cmd.builtChain.Load()
+
+	other.AddMidlewares(func(inner RunFunc) RunFunc { return inner })
+	other.AddCommand(&TestCommand{}, NewTrigger("test"))
+	container.AddCommand(&TestCommand{}, NewTrigger("other"))
+	container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+	if cmd.builtChain.Load() != built {
+		t.Error("Chain was rebuilt after changes that don't affect it")
+	}
+
+	container.Sub("sub").AddMidlewares(func(inner RunFunc) RunFunc { return inner })
+	container.Run(&Data{MsgStrippedPrefix: "test", Source: PrefixSource})
+	if cmd.builtChain.Load() != built {
+		t.Error("Chain was rebuilt after a change to a sub container")
+	}
+}
+
+func TestMiddlewareChainContainerChain(t *testing.T) {
+	first := &Container{}
+	cmd := first.AddCommand(&TestCommand{}, NewTrigger("test"))
+
+	calls := 0
+	second := &Container{}
+	second.AddMidlewares(countingMiddleware(&calls))
+
+	cmd.middlewareChain([]*Container{first})(&Data{})
+	cmd.middlewareChain([]*Container{second})(&Data{})
+	if calls != 1 {
+		t.Error("Chain built from a different container chain was used, calls:", calls)
+	}
+}
@@ -0,0 +1,234 @@
+package dcmd
+
+import (
+	"github.com/pkg/errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidNumber   = errors.New("invalid number")
+	errDivisionByZero  = errors.New("division by zero")
+	errPercentNotAllow = errors.New("percentages not allowed")
+)
+
+// NumberUnits are the suffixes accepted by number args with AllowUnits set, matching is case insensitive
+var NumberUnits = map[byte]float64{
+	'k': 1e3,
+	'm': 1e6,
+	'b': 1e9,
+}
+
+// numberParser parses numbers with unit suffixes ("2.5k"), thousands separators ("1,000"), percentages ("10%")
+// and if exprs is set simple arithmetic using + - * / and parentheses ("5*60")
+type numberParser struct {
+	in  string
+	pos int
+
+	units bool
+	exprs bool
+
+	// percentBase is what percentages are relative to, percentages are not allowed if it's nil
+	percentBase *float64
+}
+
+// parseNumber parses in, see numberParser
+func parseNumber(in string, units, exprs bool, percentBase *float64) (float64, error) {
+	p := &numberParser{in: in, units: units, exprs: exprs, percentBase: percentBase}
+
+	var v float64
+	var err error
+	if exprs {
+		v, err = p.expr()
+	} else {
+		v, err = p.unary()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	p.skipSpaces()
+	if p.pos != len(p.in) {
+		return 0, errInvalidNumber
+	}
+
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errInvalidNumber
+	}
+
+	return v, nil
+}
+
+func (p *numberParser) skipSpaces() {
+	for p.pos < len(p.in) && p.in[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *numberParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.in) {
+		return 0
+	}
+
+	return p.in[p.pos]
+}
+
+// expr = term (("+" | "-") term)*
+func (p *numberParser) expr() (float64, error) {
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return v, nil
+		}
+		p.pos++
+
+		right, err := p.term()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == '+' {
+			v += right
+		} else {
+			v -= right
+		}
+	}
+}
+
+// term = unary (("*" | "/") unary)*
+func (p *numberParser) term() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return v, nil
+		}
+		p.pos++
+
+		right, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == '*' {
+			v *= right
+		} else {
+			if right == 0 {
+				return 0, errDivisionByZero
+			}
+			v /= right
+		}
+	}
+}
+
+// unary = ("-" | "+") unary | "(" expr ")" | number
+func (p *numberParser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary()
+	case '(':
+		if !p.exprs {
+			return 0, errInvalidNumber
+		}
+		p.pos++
+
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+
+		if p.peek() != ')' {
+			return 0, errInvalidNumber
+		}
+		p.pos++
+		return v, nil
+	}
+
+	return p.number()
+}
+
+// number = digits ["." digits] [unit] ["%"], where digits can have thousands separators if units are allowed
+func (p *numberParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.in) && (isDigit(p.in[p.pos]) || (p.units && p.in[p.pos] == ',')) {
+		p.pos++
+	}
+
+	intPart := p.in[start:p.pos]
+	if strings.Contains(intPart, ",") {
+		groups := strings.Split(intPart, ",")
+		if len(groups[0]) < 1 || len(groups[0]) > 3 {
+			return 0, errInvalidNumber
+		}
+
+		for _, v := range groups[1:] {
+			if len(v) != 3 {
+				return 0, errInvalidNumber
+			}
+		}
+
+		intPart = strings.Replace(intPart, ",", "", -1)
+	}
+
+	fracPart := ""
+	if p.pos < len(p.in) && p.in[p.pos] == '.' {
+		p.pos++
+		fracStart := p.pos
+		for p.pos < len(p.in) && isDigit(p.in[p.pos]) {
+			p.pos++
+		}
+		fracPart = p.in[fracStart:p.pos]
+	}
+
+	if intPart == "" && fracPart == "" {
+		return 0, errInvalidNumber
+	}
+
+	v, err := strconv.ParseFloat(intPart+"."+fracPart, 64)
+	if err != nil {
+		return 0, errInvalidNumber
+	}
+
+	if p.units && p.pos < len(p.in) {
+		c := p.in[p.pos]
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+
+		if mul, ok := NumberUnits[c]; ok {
+			v *= mul
+			p.pos++
+		}
+	}
+
+	if p.pos < len(p.in) && p.in[p.pos] == '%' {
+		if p.percentBase == nil {
+			return 0, errPercentNotAllow
+		}
+
+		v = v / 100 * *p.percentBase
+		p.pos++
+	}
+
+	return v, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
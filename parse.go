@@ -2,11 +2,13 @@ package dcmd
 
 import (
 	"strings"
+	"unicode/utf8"
 )
 
 var (
 	ErrNoComboFound       = NewSimpleUserError("No matching combo found")
 	ErrNotEnoughArguments = NewSimpleUserError("Not enough arguments passed")
+	ErrTooManyArguments   = NewSimpleUserError("Too many arguments passed")
 )
 
 func ArgParserMW(inner RunFunc) RunFunc {
@@ -15,7 +17,7 @@ func ArgParserMW(inner RunFunc) RunFunc {
 		err := ParseCmdArgs(data)
 		if err != nil {
 			if IsUserError(err) {
-				return "Invalid arguments provided: " + err.Error(), nil
+				return FormatParseError(data, err), nil
 			}
 
 			return nil, err
@@ -25,6 +27,29 @@ func ArgParserMW(inner RunFunc) RunFunc {
 	}
 }
 
+// FormatParseError formats a user error returned from ParseCmdArgs, if it's a ParseError the input is echoed
+// with the offending part marked, followed by the usage of the command
+func FormatParseError(data *Data, err error) string {
+	out := "Invalid arguments provided: " + err.Error()
+
+	if pe, ok := err.(*ParseError); ok {
+		if line, marker, ok := pe.Marker(); ok {
+			// Avoid breaking out of the code block
+			line = strings.Replace(line, "`", "\u02cb", -1)
+			out += "\n```\n" + line + "\n" + marker + "\n```"
+		}
+	}
+
+	if data.Cmd != nil {
+		usage := (&StdHelpFormatter{}).ArgDefs(data.Cmd, data)
+		if usage != "" {
+			out += "\nUsage:```\n" + usage + "\n```"
+		}
+	}
+
+	return out
+}
+
 // ParseCmdArgs is the standard argument parser
 // todo, more doc on the format
 func ParseCmdArgs(data *Data) error {
@@ -37,7 +62,20 @@ func ParseCmdArgs(data *Data) error {
 	}
 
 	// Split up the args
-	split := SplitArgs(data.MsgStrippedPrefix)
+	var tokenizer Tokenizer = defaultTokenizer
+	if data.System != nil && data.System.Tokenizer != nil {
+		tokenizer = data.System.Tokenizer
+	}
+
+	var split []*RawArg
+	pooled, _ := tokenizer.(AppendTokenizer)
+	if pooled != nil {
+		buf := rawArgsPool.Get().(*rawArgsBuffer)
+		defer buf.release()
+		split = buf.tokenize(pooled, data.MsgStrippedPrefix)
+	} else {
+		split = tokenizer.Split(data.MsgStrippedPrefix)
+	}
 
 	var err error
 	if switchesOk {
@@ -48,6 +86,16 @@ func ParseCmdArgs(data *Data) error {
 			if err != nil {
 				return err
 			}
+
+			if pooled != nil {
+				// The args are reused after this returns, so give the parsed switches their own copy
+				for _, v := range data.Switches {
+					if v.Raw != nil {
+						raw := *v.Raw
+						v.Raw = &raw
+					}
+				}
+			}
 		}
 	}
 
@@ -65,55 +113,148 @@ func ParseCmdArgs(data *Data) error {
 }
 
 // ParseArgDefs parses ordered argument definition for a CmdWithArgDefs
+//
+// AttachmentArg defs are bound to the attachments of the message first and don't take up any of the text args,
+// unless there's no attachment left for them and they accept URLs instead.
+//
+// If the command was ran as a reply then the defs with FromReply set are taken from the referenced message when they're omitted,
+// the args given are first parsed with them included, so "!warn Bob reason" warns Bob while "!warn reason" warns the author of the referenced message.
+// The values from the referenced message are parsed by the type of the def like any other arg.
 func ParseArgDefs(defs []*ArgDef, required int, combos [][]int, data *Data, split []*RawArg) error {
+	parsedArgs := NewParsedArgs(defs)
+	provided := make([]bool, len(defs))
 
-	combo, ok := FindCombo(defs, combos, split)
-	if !ok {
-		return ErrNoComboFound
+	text, err := bindAttachments(defs, required, combos, data, parsedArgs, provided)
+	if err != nil {
+		return err
 	}
 
-	parsedArgs := NewParsedArgs(defs)
-	for i, v := range combo {
-		def := defs[v]
-		if i >= len(split) {
-			if i >= required && len(combos) < 1 {
-				break
-			}
-			return ErrNotEnoughArguments
+	match, candidates := MatchCombos(text.defs, text.required, text.combos, split)
+
+	if reply, fromReply := bindReply(text, data); reply != nil {
+		err = parseWithReply(defs, text, match, reply, fromReply, data, split, parsedArgs, provided)
+	} else {
+		err = parseText(defs, text, match, candidates, data, split, parsedArgs, provided)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Fill in the dynamic defaults of the omitted args
+	for k, def := range defs {
+		if provided[k] || def.DefaultFunc == nil {
+			continue
 		}
 
-		combined := ""
-		if i == len(combo)-1 && len(split)-1 > i {
-			// Last arg, but still more after, combine and rebuilt them
-			for j := i; j < len(split); j++ {
-				if j != i {
-					combined += " "
-				}
+		err := def.SetDefault(data, parsedArgs[k])
+		if err != nil {
+			return err
+		}
+	}
+
+	data.Args = parsedArgs
+
+	return nil
+}
+
+// parseText parses the args matched to the text defs, if match is nil then a NoComboFound is returned if the defs have combos,
+// otherwise the args are matched in order so that the errors from parsing them are shown
+func parseText(defs []*ArgDef, text *textArgDefs, match *ComboMatch, candidates []*ComboMatch, data *Data, split []*RawArg, parsedArgs []*ParsedArg, provided []bool) error {
+	if match != nil {
+		return parseMatch(defs, text, match, data, split, parsedArgs, provided)
+	}
 
-				temp := split[j]
-				if temp.Container != 0 {
-					combined += string(temp.Container) + temp.Str + string(temp.Container)
-				} else {
-					combined += temp.Str
+	if len(text.combos) > 0 {
+		leftOver := bestLeftOver(candidates)
+		if leftOver == nil {
+			return &NoComboFound{Candidates: candidates}
+		}
+
+		pos := 0
+		if len(leftOver.Spans) > 0 {
+			pos = leftOver.Spans[len(leftOver.Spans)-1][1]
+		}
+
+		pe := NewParseError(ErrTooManyArguments, nil, data.MsgStrippedPrefix, split, pos)
+		pe.Candidates = candidates
+		return pe
+	}
+
+	match, err := sequentialMatch(text.defs, text.required, data, split)
+	if err == nil {
+		err = parseMatch(defs, text, match, data, split, parsedArgs, provided)
+	}
+
+	if pe, ok := err.(*ParseError); ok {
+		pe.Candidates = candidates
+	}
+
+	return err
+}
+
+// bestLeftOver returns the highest scoring candidate that was only rejected because of args left over, or nil
+func bestLeftOver(candidates []*ComboMatch) *ComboMatch {
+	var best *ComboMatch
+	for _, v := range candidates {
+		if v.RejectReason != "" && v.Spans != nil && (best == nil || v.Score > best.Score) {
+			best = v
+		}
+	}
+
+	return best
+}
+
+// parseMatch parses the args matched to the text defs and puts them in parsedArgs
+func parseMatch(defs []*ArgDef, text *textArgDefs, match *ComboMatch, data *Data, split []*RawArg, parsedArgs []*ParsedArg, provided []bool) error {
+	for i, ti := range match.Combo {
+		v := text.indexes[ti]
+		def := defs[v]
+		span := match.Spans[i]
+
+		if def.Variadic {
+			vals := make([]interface{}, 0, span[1]-span[0])
+			for pos := span[0]; pos < span[1]; pos++ {
+				val, err := def.Type.Parse(def, split[pos].Str, data)
+				if err == nil {
+					err = def.Validate(data, val)
 				}
+				if err != nil {
+					return NewParseError(err, def, data.MsgStrippedPrefix, split, pos)
+				}
+
+				vals = append(vals, val)
 			}
-		} else {
-			combined = split[i].Str
+
+			parsedArgs[v].Value = vals
+			provided[v] = true
+			continue
 		}
 
-		val, err := def.Type.Parse(def, combined, data)
+		part := split[span[0]].Str
+		if _, ok := def.Type.(*RemainderArg); ok {
+			part = remainder(data, split, span[0])
+		}
+
+		val, err := def.Type.Parse(def, part, data)
+		if err == nil {
+			err = def.Validate(data, val)
+		}
 		if err != nil {
-			return err
+			return NewParseError(err, def, data.MsgStrippedPrefix, split, span[0])
 		}
+
 		parsedArgs[v].Value = val
+		provided[v] = true
 	}
 
-	data.Args = parsedArgs
-
 	return nil
 }
 
 // ParseSwitches parses all switches for a CmdWithSwitches, and also takes them out of the raw args
+//
+// Switches can be passed as "-name", "--name", "-name=value" or "--name=value" where name is either the Switch or one of the SwitchAliases,
+// single character boolean switches can be combined ("-ab"), and "--" stops switch parsing for the rest of the args.
+// Repeating a Variadic switch collects the values in a []interface{}, otherwise the last one is used.
 func ParseSwitches(switches []*ArgDef, data *Data, split []*RawArg) ([]*RawArg, error) {
 	newRaws := make([]*RawArg, 0, len(split))
 
@@ -128,155 +269,384 @@ func ParseSwitches(switches []*ArgDef, data *Data, split []*RawArg) ([]*RawArg,
 
 	for i := 0; i < len(split); i++ {
 		raw := split[i]
-		if raw.Container != 0 {
+		if raw.Container != 0 || !strings.HasPrefix(raw.Str, "-") || raw.Str == "-" {
 			newRaws = append(newRaws, raw)
 			continue
 		}
 
-		if !strings.HasPrefix(raw.Str, "-") {
-			newRaws = append(newRaws, raw)
-			continue
+		if raw.Str == "--" {
+			// Stop parsing switches, the rest are plain args
+			newRaws = append(newRaws, split[i+1:]...)
+			break
 		}
 
 		rest := raw.Str[1:]
-		var matchedArg *ArgDef
-		for _, v := range switches {
-			if v.Switch == rest {
-				matchedArg = v
-				break
-			}
+		long := strings.HasPrefix(rest, "-")
+		if long {
+			rest = rest[1:]
 		}
 
+		value := ""
+		hasValue := false
+		if eq := strings.Index(rest, "="); eq != -1 {
+			rest, value, hasValue = rest[:eq], rest[eq+1:], true
+		}
+
+		matchedArg := FindSwitch(switches, rest)
 		if matchedArg == nil {
+			if combined := combinedSwitches(switches, rest); !long && !hasValue && combined != nil {
+				for _, v := range combined {
+					setSwitch(parsedSwitches[v.Switch], raw, true)
+				}
+				continue
+			}
+
 			newRaws = append(newRaws, raw)
 			continue
 		}
 
+		var val interface{} = true
 		if matchedArg.Type == nil {
-			parsedSwitches[matchedArg.Switch].Raw = raw
-			parsedSwitches[matchedArg.Switch].Value = true
-			continue
+			if hasValue {
+				parsed, err := Bool.Parse(matchedArg, value, data)
+				if err != nil {
+					return nil, NewParseError(err, matchedArg, data.MsgStrippedPrefix, split, i)
+				}
+				val = parsed
+			}
+		} else {
+			if !hasValue {
+				if i >= len(split)-1 {
+					return nil, NewParseError(&MissingSwitchValue{Switch: matchedArg.Switch, TypeName: matchedArg.Type.HelpName()}, matchedArg, data.MsgStrippedPrefix, split, i)
+				}
+
+				// At this point, we have encountered a switch with data
+				// so we need to skip the next RawArg
+				i++
+				value = split[i].Str
+			}
+
+			parsed, err := matchedArg.Type.Parse(matchedArg, value, data)
+			if err != nil {
+				return nil, NewParseError(err, matchedArg, data.MsgStrippedPrefix, split, i)
+			}
+			val = parsed
 		}
 
-		if i >= len(split)-1 {
-			// A switch with extra stuff requird, but no extra data provided
-			// Can't handle this case...
-			continue
+		err := matchedArg.Validate(data, val)
+		if err != nil {
+			return nil, NewParseError(err, matchedArg, data.MsgStrippedPrefix, split, i)
 		}
 
-		// At this point, we have encountered a switch with data
-		// so we need to skip the next RawArg
-		i++
+		setSwitch(parsedSwitches[matchedArg.Switch], raw, val)
+	}
+
+	err := CheckSwitchRelations(switches, parsedSwitches)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in the dynamic defaults of the switches not provided
+	for _, v := range parsedSwitches {
+		if v.Raw != nil || v.Def.DefaultFunc == nil {
+			continue
+		}
 
-		val, err := matchedArg.Type.Parse(matchedArg, split[i].Str, data)
+		err := v.Def.SetDefault(data, v)
 		if err != nil {
-			// TODO: Use custom error type for helpfull errror
 			return nil, err
 		}
-
-		parsedSwitches[matchedArg.Switch].Raw = raw
-		parsedSwitches[matchedArg.Switch].Value = val
 	}
+
 	data.Switches = parsedSwitches
 	return newRaws, nil
 }
 
-var (
-	ArgContainers = []rune{
-		'"',
-		'`',
+// CheckSwitchRelations checks the Required, ExclusiveGroup and Requires constraints of the switches
+// a switch is considered provided if the ParsedArg's Raw is set, parsed is keyed by the Switch name and not the aliases
+func CheckSwitchRelations(switches []*ArgDef, parsed map[string]*ParsedArg) error {
+	provided := func(name string) bool {
+		p, ok := parsed[name]
+		return ok && p.Raw != nil
 	}
-)
 
-type RawArg struct {
-	Str       string
-	Container rune
-}
-
-// SplitArgs splits the string into fields
-func SplitArgs(in string) []*RawArg {
-	rawArgs := make([]*RawArg, 0)
-
-	curBuf := ""
-	escape := false
-	var container rune
-	for _, r := range in {
-		// Apply or remove escape mode
-		if r == '\\' {
-			if escape {
-				escape = false
-				curBuf += "\\"
-			} else {
-				escape = true
+	exclusive := make(map[string]string)
+	for _, v := range switches {
+		if !provided(v.Switch) {
+			if v.Required {
+				return &MissingRequiredSwitch{Switch: v.Switch}
 			}
 
 			continue
 		}
 
-		// Check for other special tokens
-		isSpecialToken := true
-		if r == ' ' {
-			// Maybe seperate by space
-			if curBuf != "" && container == 0 && !escape {
-				rawArgs = append(rawArgs, &RawArg{curBuf, 0})
-				curBuf = ""
-			} else if curBuf != "" {
-				curBuf += " "
+		if v.ExclusiveGroup != "" {
+			if other, ok := exclusive[v.ExclusiveGroup]; ok {
+				return &ExclusiveSwitches{A: other, B: v.Switch}
 			}
-		} else if r == container && container != 0 {
-			// Split arg here
-			if escape {
-				curBuf += string(r)
-			} else {
-				rawArgs = append(rawArgs, &RawArg{curBuf, container})
-				curBuf = ""
-				container = 0
+			exclusive[v.ExclusiveGroup] = v.Switch
+		}
+
+		for _, req := range v.Requires {
+			// Requires can refer to the aliases of the other switch
+			if sw := FindSwitch(switches, req); sw != nil {
+				req = sw.Switch
 			}
-		} else if container == 0 && curBuf == "" {
-			// Check if we should start containing a arg
-			foundMatch := false
-			for _, v := range ArgContainers {
-				if v == r {
-					if escape {
-						curBuf += string(r)
-					} else {
-						container = v
-					}
-					foundMatch = true
-					break
-				}
+
+			if !provided(req) {
+				return &SwitchRequires{Switch: v.Switch, Requires: req}
 			}
+		}
+	}
 
-			if !foundMatch {
-				isSpecialToken = false
+	return nil
+}
+
+// FindSwitch returns the switch with the name or alias, or nil if none found
+func FindSwitch(switches []*ArgDef, name string) *ArgDef {
+	for _, v := range switches {
+		if v.Switch == name {
+			return v
+		}
+
+		for _, alias := range v.SwitchAliases {
+			if alias == name {
+				return v
 			}
-		} else {
-			isSpecialToken = false
 		}
+	}
+
+	return nil
+}
+
+// combinedSwitches returns the switches for combined single character boolean switches (e.g "ab" in "-ab"),
+// or nil if any of the characters are not a boolean switch
+func combinedSwitches(switches []*ArgDef, names string) []*ArgDef {
+	if names == "" {
+		return nil
+	}
+
+	out := make([]*ArgDef, 0, len(names))
+	for _, r := range names {
+		sw := FindSwitch(switches, string(r))
+		if sw == nil || sw.Type != nil {
+			return nil
+		}
+
+		out = append(out, sw)
+	}
+
+	return out
+}
+
+// setSwitch sets the value of a parsed switch, appending to the previous values if it's variadic
+func setSwitch(p *ParsedArg, raw *RawArg, val interface{}) {
+	if !p.Def.Variadic {
+		p.Value = val
+		p.Raw = raw
+		return
+	}
+
+	// Replace the default on the first occurrence
+	vals, _ := p.Value.([]interface{})
+	if p.Raw == nil {
+		vals = nil
+	}
+
+	p.Value = append(vals, val)
+	p.Raw = raw
+}
+
+type RawArg struct {
+	Str string
+	// The rune that opened the container the arg was in, or 0 if it was not in one
+	Container rune
+	// The language tag of a ``` code block
+	Lang string
+
+	// Start and End are the byte offsets of the arg in the string passed to SplitArgs, including the containers
+	Start, End int
+}
+
+// ParseError is returned by ParseArgDefs and ParseSwitches when a argument failed parsing, it wraps the actual error
+type ParseError struct {
+	Err error
+
+	// ArgName and Expected is empty if the error is not tied to a specific arg (such as too many arguments)
+	ArgName  string
+	Expected string
+
+	// Index of the raw arg in the split input, if it's equal to the length of it then it's past the end of the input
+	Index int
+	Part  string
+
+	// The input the args were split from, Start and End are the byte offsets of the part in it
+	// Start is -1 if the input is not known
+	Input      string
+	Start, End int
+
+	// The combos that were considered before falling back to matching the args in order, along with why they were rejected.
+	// Only set by ParseArgDefs, useful for debugging, see FormatCandidates
+	Candidates []*ComboMatch
+}
+
+// NewParseError creates a new ParseError from split[index], input should be the string split was created from
+func NewParseError(err error, def *ArgDef, input string, split []*RawArg, index int) *ParseError {
+	pe := &ParseError{
+		Err:   err,
+		Index: index,
+		Input: input,
+		Start: -1,
+	}
+
+	if def != nil {
+		pe.ArgName = argName(def)
+		pe.Expected = "Switch"
+		if def.Type != nil {
+			pe.Expected = def.Type.HelpName()
+		}
+	}
+
+	if index < len(split) {
+		pe.Part = split[index].Str
+	}
+
+	// Make sure split was actually from the input, switches may have been removed from it so it doesn't have to cover all of it
+	if len(split) < 1 || !inInput(input, split) {
+		return pe
+	}
+
+	if index < len(split) {
+		pe.Start = split[index].Start
+		pe.End = split[index].End
+	} else {
+		pe.Start = len(input)
+		pe.End = len(input)
+	}
+
+	return pe
+}
+
+func (p *ParseError) Error() string {
+	return p.Err.Error()
+}
+
+// Cause returns the wrapped error, this is used by IsUserError
+func (p *ParseError) Cause() error {
+	return p.Err
+}
+
+// Marker returns the line of the input containing the part, and a marker line pointing at the part
+func (p *ParseError) Marker() (line string, marker string, ok bool) {
+	if p.Start < 0 || p.Start > len(p.Input) {
+		return "", "", false
+	}
+
+	lineStart := strings.LastIndex(p.Input[:p.Start], "\n") + 1
+	lineEnd := strings.Index(p.Input[p.Start:], "\n")
+	if lineEnd == -1 {
+		lineEnd = len(p.Input)
+	} else {
+		lineEnd += p.Start
+	}
+
+	end := p.End
+	if end > lineEnd {
+		end = lineEnd
+	}
+
+	line = p.Input[lineStart:lineEnd]
+
+	width := utf8.RuneCountInString(p.Input[p.Start:end])
+	if width < 1 {
+		width = 1
+		if p.Start == len(p.Input) {
+			// Past the end, point to the space after
+			line += " "
+		}
+	}
 
-		if !isSpecialToken {
-			if escape {
-				curBuf += "\\"
+	marker = strings.Repeat(" ", utf8.RuneCountInString(p.Input[lineStart:p.Start])) + strings.Repeat("^", width)
+	return line, marker, true
+}
+
+// remainder returns the rest of data.MsgStrippedPrefix starting at split[pos], built from the source of the remaining args.
+// The spacing between args next to each other is kept, while args that were taken out (such as switches) are replaced by a single space
+// and trailing whitespace is dropped. If split is not from MsgStrippedPrefix it falls back to joining the args with spaces.
+func remainder(data *Data, split []*RawArg, pos int) string {
+	in := data.MsgStrippedPrefix
+	rest := split[pos:]
+
+	var combined strings.Builder
+	if !inInput(in, rest) {
+		for j, temp := range rest {
+			if j != 0 {
+				combined.WriteByte(' ')
+			}
+
+			if temp.Container != 0 {
+				combined.WriteRune(temp.Container)
+				combined.WriteString(temp.Str)
+				combined.WriteRune(temp.Container)
+			} else {
+				combined.WriteString(temp.Str)
 			}
-			curBuf += string(r)
 		}
 
-		// Reset escape mode
-		escape = false
+		return combined.String()
 	}
 
-	// Something was left in the buffer just add it to the end
-	if curBuf != "" {
-		if container != 0 {
-			curBuf = string(container) + curBuf
+	combined.Grow(rest[len(rest)-1].End - rest[0].Start)
+	for j, temp := range rest {
+		if j != 0 {
+			gap := in[rest[j-1].End:temp.Start]
+			if strings.TrimSpace(gap) == "" {
+				combined.WriteString(gap)
+			} else {
+				// Something was taken out in between
+				combined.WriteByte(' ')
+			}
 		}
-		rawArgs = append(rawArgs, &RawArg{curBuf, 0})
+
+		combined.WriteString(in[temp.Start:temp.End])
 	}
 
-	return rawArgs
+	return combined.String()
 }
 
-// Finds a proper argument combo from the provided args
+// inInput returns true if the Start and End of the args are valid and in order for in, and the text of each arg is from that part of in.
+// Quotes, escapes and code block fences are removed from the text, so it only has to be a subsequence of the part
+func inInput(in string, split []*RawArg) bool {
+	last := 0
+	for _, v := range split {
+		if v.Start < last || v.End < v.Start || v.End > len(in) || !isSubsequence(v.Str, in[v.Start:v.End]) {
+			return false
+		}
+		last = v.End
+	}
+
+	return true
+}
+
+// isSubsequence returns true if the bytes of sub appear in s in the same order
+func isSubsequence(sub, s string) bool {
+	j := 0
+	for i := 0; i < len(s) && j < len(sub); i++ {
+		if s[i] == sub[j] {
+			j++
+		}
+	}
+
+	return j == len(sub)
+}
+
+// SplitArgs splits the string into fields using the standard tokenizer, see StdTokenizer
+func SplitArgs(in string) []*RawArg {
+	return defaultTokenizer.Split(in)
+}
+
+// FindCombo finds a proper argument combo from the provided args, if there's no combos all the defs are returned
+// See MatchCombos for more details on how the combo is selected
 func FindCombo(defs []*ArgDef, combos [][]int, args []*RawArg) (combo []int, ok bool) {
 
 	if len(combos) < 1 {
@@ -287,31 +657,62 @@ func FindCombo(defs []*ArgDef, combos [][]int, args []*RawArg) (combo []int, ok
 		return out, true
 	}
 
-	var selectedCombo []int
+	match, _ := MatchCombos(defs, len(defs), combos, args)
+	if match == nil {
+		return nil, false
+	}
+
+	return match.Combo, true
+}
 
-	// Find a possible match
-OUTER:
-	for _, combo := range combos {
-		if len(combo) > len(args) {
-			// No match
-			continue
-		}
+// sequentialMatch assigns the args to the defs in order without checking if they match,
+// returning a ParseError if there's not enough or too many args
+func sequentialMatch(defs []*ArgDef, required int, data *Data, split []*RawArg) (*ComboMatch, error) {
+	match := &ComboMatch{}
 
-		// See if this combos arguments matches that of the parsed command
-		for i, comboArg := range combo {
-			def := defs[comboArg]
+	pos := 0
+	for i, def := range defs {
+		if pos >= len(split) {
+			if i >= required {
+				break
+			}
+			return nil, NewParseError(ErrNotEnoughArguments, def, data.MsgStrippedPrefix, split, pos)
+		}
 
-			if !def.Type.Matches(def, args[i].Str) {
-				continue OUTER
+		end := pos + 1
+		if _, ok := def.Type.(*RemainderArg); ok {
+			end = len(split)
+		} else if def.Variadic {
+			// Leave enough parts for the remaining required args
+			reserve := required - 1 - i
+			if reserve < 0 {
+				reserve = 0
 			}
+			end = variadicEnd(def, split, pos, reserve)
 		}
 
-		// We got a match, if this match is stronger than the last one set it as selected
-		if len(combo) > len(selectedCombo) || !ok {
-			selectedCombo = combo
-			ok = true
+		match.Combo = append(match.Combo, i)
+		match.Spans = append(match.Spans, [2]int{pos, end})
+		pos = end
+	}
+
+	if pos < len(split) {
+		return nil, NewParseError(ErrTooManyArguments, nil, data.MsgStrippedPrefix, split, pos)
+	}
+
+	return match, nil
+}
+
+// variadicEnd returns the index of the first part after start that should not be consumed by the variadic def,
+// the variadic arg consumes all matching parts while leaving atleast one part for each of the reserved args after it
+func variadicEnd(def *ArgDef, split []*RawArg, start int, reserve int) int {
+	end := start + 1
+	for end < len(split)-reserve {
+		if !def.Type.Matches(def, split[end].Str) {
+			break
 		}
+		end++
 	}
 
-	return selectedCombo, ok
+	return end
 }
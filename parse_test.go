@@ -2,6 +2,7 @@ package dcmd
 
 import (
 	"fmt"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -22,11 +23,15 @@ func TestParseArgDefs(t *testing.T) {
 		{"escape space", "first\\ still\\ first second", []*ArgDef{{Type: String}, {Type: String}}, []*ParsedArg{{Value: "first still first"}, {Value: "second"}}},
 		{"escape container", "`first \\` still first` second", []*ArgDef{{Type: String}, {Type: String}}, []*ParsedArg{{Value: "first ` still first"}, {Value: "second"}}},
 		{"keep escape character", "first\\n second", []*ArgDef{{Type: String}, {Type: String}}, []*ParsedArg{{Value: "first\\n"}, {Value: "second"}}},
+		{"variadic", "1 2 3", []*ArgDef{{Type: Int, Variadic: true}}, []*ParsedArg{{Value: []interface{}{int64(1), int64(2), int64(3)}}}},
+		{"variadic string", "1 2 3 reason here", []*ArgDef{{Type: Int, Variadic: true}, {Type: Remainder}}, []*ParsedArg{{Value: []interface{}{int64(1), int64(2), int64(3)}}, {Value: "reason here"}}},
+		{"remainder", "first  `second`\n**third**", []*ArgDef{{Type: String}, {Type: Remainder}}, []*ParsedArg{{Value: "first"}, {Value: "`second`\n**third**"}}},
+		{"variadic int", "hello 1 2 3", []*ArgDef{{Type: String}, {Type: Int, Variadic: true}}, []*ParsedArg{{Value: "hello"}, {Value: []interface{}{int64(1), int64(2), int64(3)}}}},
 	}
 
 	for i, v := range cases {
 		t.Run(fmt.Sprintf("#%d-%s", i, v.name), func(t *testing.T) {
-			d := new(Data)
+			d := &Data{MsgStrippedPrefix: v.input}
 			err := ParseArgDefs(v.defs, 0, nil, d, SplitArgs(v.input))
 
 			if err != nil {
@@ -63,6 +68,13 @@ func TestParseSwitches(t *testing.T) {
 		{"int float", "-i 15 -f 30.5", []*ArgDef{{Switch: "i", Type: Int}, {Switch: "f", Type: Float}}, []*ParsedArg{{Value: int64(15)}, {Value: float64(30.5)}}},
 		{"string int", "-s hey_man -i 30", []*ArgDef{{Switch: "s", Type: String}, {Switch: "i", Type: Int}}, []*ParsedArg{{Value: "hey_man"}, {Value: int64(30)}}},
 		{"quoted strings", "-s1 first -s2 `middle quoted` -s3 last", []*ArgDef{{Switch: "s1", Type: String}, {Switch: "s2", Type: String}, {Switch: "s3", Type: String}}, []*ParsedArg{{Value: "first"}, {Value: "middle quoted"}, {Value: "last"}}},
+		{"long switch", "--string hello", []*ArgDef{{Switch: "string", Type: String}}, []*ParsedArg{{Value: "hello"}}},
+		{"alias", "-s hello", []*ArgDef{{Switch: "string", SwitchAliases: []string{"s"}, Type: String}}, []*ParsedArg{{Value: "hello"}}},
+		{"key value", "--int=15 -f=1.5", []*ArgDef{{Switch: "int", Type: Int}, {Switch: "f", Type: Float}}, []*ParsedArg{{Value: int64(15)}, {Value: float64(1.5)}}},
+		{"combined bools", "-ab", []*ArgDef{{Switch: "a"}, {Switch: "b"}, {Switch: "c"}}, []*ParsedArg{{Value: true}, {Value: true}, {Value: nil}}},
+		{"bool value", "--a=off", []*ArgDef{{Switch: "a"}}, []*ParsedArg{{Value: false}}},
+		{"terminator", "-a -- -b", []*ArgDef{{Switch: "a"}, {Switch: "b"}}, []*ParsedArg{{Value: true}, {Value: nil}}},
+		{"repeated", "-i 1 -i 2", []*ArgDef{{Switch: "i", Type: Int, Variadic: true, Default: []interface{}{int64(5)}}}, []*ParsedArg{{Value: []interface{}{int64(1), int64(2)}}}},
 	}
 
 	for i, v := range cases {
@@ -81,3 +93,180 @@ func TestParseSwitches(t *testing.T) {
 		})
 	}
 }
+
+func TestArgValidators(t *testing.T) {
+	notTen := func(data *Data, value interface{}) error {
+		if value.(int64) == 10 {
+			return NewSimpleUserError("Can't be 10")
+		}
+		return nil
+	}
+
+	defs := []*ArgDef{{Name: "amount", Type: Int, Variadic: true, Validators: []ArgValidator{notTen}}}
+
+	err := ParseArgDefs(defs, 0, nil, new(Data), SplitArgs("5 6"))
+	assert.NoError(t, err, "Should pass validation")
+
+	err = ParseArgDefs(defs, 0, nil, new(Data), SplitArgs("5 10"))
+	assert.Error(t, err, "Should fail validation")
+	assert.True(t, IsUserError(err), "Should be a user error")
+	assert.Equal(t, "amount: Can't be 10", err.Error())
+}
+
+func TestArgDefaultFunc(t *testing.T) {
+	calls := 0
+	defs := []*ArgDef{{Name: "amount", Type: Int, Default: int64(1)}, {Name: "other", Type: Int, DefaultFunc: func(data *Data) (interface{}, error) {
+		calls++
+		return int64(5), nil
+	}}}
+
+	d := new(Data)
+	err := ParseArgDefs(defs, 0, nil, d, SplitArgs("2"))
+	assert.NoError(t, err, "Should parse sucessfully")
+	assert.Equal(t, int64(2), d.Args[0].Value)
+	assert.Equal(t, int64(5), d.Args[1].Value)
+
+	err = ParseArgDefs(defs, 0, nil, d, SplitArgs("2 3"))
+	assert.NoError(t, err, "Should parse sucessfully")
+	assert.Equal(t, int64(3), d.Args[1].Value)
+	assert.Equal(t, 1, calls, "DefaultFunc should only be called when omitted")
+
+	_, err = ParseSwitches([]*ArgDef{{Switch: "s", Type: Int, DefaultFunc: defs[1].DefaultFunc}}, d, SplitArgs(""))
+	assert.NoError(t, err, "Should parse sucessfully")
+	assert.Equal(t, int64(5), d.Switches["s"].Value)
+}
+
+func TestParseArgDefsTooMany(t *testing.T) {
+	err := ParseArgDefs([]*ArgDef{{Type: Int}}, 0, nil, new(Data), SplitArgs("1 2"))
+	assert.Equal(t, ErrTooManyArguments, errors.Cause(err))
+}
+
+func TestParseErrorMarker(t *testing.T) {
+	cases := []struct {
+		input  string
+		defs   []*ArgDef
+		line   string
+		marker string
+	}{
+		{"10 abc", []*ArgDef{{Type: Int}, {Type: Int}}, "10 abc", "   ^^^"},
+		{"10", []*ArgDef{{Type: Int}, {Type: Int}}, "10 ", "  ^"},
+		{"10 20 30", []*ArgDef{{Type: Int}, {Type: Int}}, "10 20 30", "      ^^"},
+		{"10 `æøå`", []*ArgDef{{Type: Int}, {Type: Int}}, "10 `æøå`", "   ^^^^^"},
+		{"10 abc -a", []*ArgDef{{Type: Int}, {Type: Int}}, "10 abc -a", "   ^^^"},
+		{"10 -a", []*ArgDef{{Type: Int}, {Type: Int}}, "10 -a ", "     ^"},
+		{"10 -a abc", []*ArgDef{{Type: Int}, {Type: Int}}, "10 -a abc", "      ^^^"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			d := &Data{MsgStrippedPrefix: c.input}
+			split, err := ParseSwitches([]*ArgDef{{Switch: "a"}}, d, SplitArgs(c.input))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			err = ParseArgDefs(c.defs, 2, nil, d, split)
+			pe, ok := err.(*ParseError)
+			if !assert.True(t, ok, "Should be a ParseError") {
+				return
+			}
+
+			line, marker, ok := pe.Marker()
+			assert.True(t, ok, "Should have a marker")
+			assert.Equal(t, c.line, line)
+			assert.Equal(t, c.marker, marker)
+		})
+	}
+}
+
+func TestParseSwitchesRest(t *testing.T) {
+	d := new(Data)
+	rest, err := ParseSwitches([]*ArgDef{{Switch: "a"}, {Switch: "i", Type: Int}}, d, SplitArgs("hello -a -5 -- -i"))
+	assert.NoError(t, err, "Should parse sucessfully")
+	if assert.Len(t, rest, 3) {
+		assert.Equal(t, "hello", rest[0].Str)
+		assert.Equal(t, "-5", rest[1].Str)
+		assert.Equal(t, "-i", rest[2].Str)
+	}
+
+	_, err = ParseSwitches([]*ArgDef{{Switch: "i", Type: Int}}, d, SplitArgs("hello -i"))
+	assert.Error(t, err, "Should fail with a missing value")
+	assert.True(t, IsUserError(err), "Should be a user error")
+}
+
+func TestSwitchRelations(t *testing.T) {
+	switches := []*ArgDef{
+		{Switch: "ban", SwitchAliases: []string{"b"}, ExclusiveGroup: "action"},
+		{Switch: "kick", ExclusiveGroup: "action"},
+		{Switch: "days", Type: Int, Requires: []string{"ban"}},
+		{Switch: "reason", Type: String, Required: true},
+		{Switch: "silent", Requires: []string{"b"}},
+	}
+
+	cases := []struct {
+		input string
+		err   error
+	}{
+		{"-ban -reason spam", nil},
+		{"-kick -reason spam", nil},
+		{"-ban -days 7 -reason spam", nil},
+		{"-ban -kick -reason spam", &ExclusiveSwitches{A: "ban", B: "kick"}},
+		{"-kick -days 7 -reason spam", &SwitchRequires{Switch: "days", Requires: "ban"}},
+		{"-ban", &MissingRequiredSwitch{Switch: "reason"}},
+		{"-b -silent -reason spam", nil},
+		{"-kick -silent -reason spam", &SwitchRequires{Switch: "silent", Requires: "ban"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			_, err := ParseSwitches(switches, new(Data), SplitArgs(c.input))
+			assert.Equal(t, c.err, err)
+		})
+	}
+}
+
+func TestRemainderSwitches(t *testing.T) {
+	switches := []*ArgDef{{Switch: "d", Type: Int}, {Switch: "s"}}
+	defs := []*ArgDef{{Type: UserID}, {Type: Remainder}}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"1 reason here -d 5", "reason here"},
+		{"1 reason  here -s", "reason  here"},
+		{"1 reason -d 5 here", "reason here"},
+		{"1 reason\n  `here` -s  and\nmore", "reason\n  `here` and\nmore"},
+		{"1 reason here   ", "reason here"},
+		{"1 -d 5 reason here  ", "reason here"},
+		{"1 reason -- -d 5", "reason -d 5"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			d := &Data{MsgStrippedPrefix: c.input}
+			split, err := ParseSwitches(switches, d, SplitArgs(c.input))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			err = ParseArgDefs(defs, 2, nil, d, split)
+			if assert.NoError(t, err) {
+				assert.Equal(t, c.expected, d.Args[1].Str())
+			}
+		})
+	}
+}
+
+func BenchmarkParseArgDefs(b *testing.B) {
+	defs := []*ArgDef{{Type: UserID}, {Type: Int}, {Type: Remainder}}
+	in := "105487308693757952 10 some reason with spaces \"and quotes\""
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data := &Data{MsgStrippedPrefix: in}
+		err := ParseArgDefs(defs, 2, nil, data, SplitArgs(in))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
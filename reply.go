@@ -0,0 +1,210 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"strconv"
+)
+
+// ReplySource is what a ArgDef takes from the referenced message if it's omitted and the command was ran as a reply, see ArgDef.FromReply
+type ReplySource int
+
+const (
+	ReplyNone ReplySource = iota
+
+	// The author of the referenced message, parsed by the type of the def as a mention of them
+	ReplyAuthor
+
+	// The content of the referenced message, parsed by the type of the def as if it was the arg
+	ReplyContent
+
+	// The referenced message itself, parsed by the type of the def as "channelID-messageID" (for MessageArg's the Message of the MessageRef is set to it)
+	ReplyMessage
+)
+
+// ReferencedMessage returns the message the command was ran as a reply to, or nil
+func (d *Data) ReferencedMessage() *discordgo.Message {
+	if d.Msg == nil {
+		return nil
+	}
+
+	return d.Msg.ReferencedMessage
+}
+
+// replyPart returns the text that refers to what def takes from the referenced message, and the data to parse it with.
+// The author is given as a mention, the content as is and the message as "channelID-messageID", so that they're parsed and checked by the type of the def like any other arg
+func replyPart(def *ArgDef, data *Data) (part string, parseData *Data, ok bool) {
+	msg := data.Msg
+	if def.FromReply == ReplyNone || msg == nil {
+		return "", nil, false
+	}
+
+	ref := msg.ReferencedMessage
+	switch def.FromReply {
+	case ReplyAuthor:
+		if ref == nil || ref.Author == nil {
+			return "", nil, false
+		}
+
+		// The mention has to be in the mentions of the message to be parsed
+		msgCop := *msg
+		msgCop.Mentions = append([]*discordgo.User{ref.Author}, msg.Mentions...)
+		dataCop := *data
+		dataCop.Msg = &msgCop
+
+		return "<@" + strconv.FormatInt(ref.Author.ID, 10) + ">", &dataCop, true
+	case ReplyContent:
+		if ref == nil || ref.Content == "" {
+			return "", nil, false
+		}
+
+		return ref.Content, data, true
+	case ReplyMessage:
+		if msg.MessageReference == nil || msg.MessageReference.MessageID == 0 {
+			return "", nil, false
+		}
+
+		channelID := msg.MessageReference.ChannelID
+		if channelID == 0 {
+			channelID = msg.ChannelID
+		}
+
+		return strconv.FormatInt(channelID, 10) + "-" + strconv.FormatInt(msg.MessageReference.MessageID, 10), data, true
+	}
+
+	return "", nil, false
+}
+
+// parseReplyValue parses the value of def from the referenced message
+func parseReplyValue(def *ArgDef, data *Data) (interface{}, error) {
+	part, parseData, ok := replyPart(def, data)
+	if !ok {
+		return nil, ErrNotEnoughArguments
+	}
+
+	ref := data.Msg.ReferencedMessage
+
+	t := def.Type
+	if ma, ok := t.(*MessageArg); ok && ma.Fetch && ref != nil {
+		// We already have the message
+		t = &MessageArg{}
+	}
+
+	val, err := t.Parse(def, part, parseData)
+	if err == nil {
+		err = def.Validate(data, val)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mr, ok := val.(*MessageRef); ok && mr.Message == nil && ref != nil && ref.ID == mr.MessageID {
+		mr.Message = ref
+	}
+
+	return val, nil
+}
+
+// bindReply returns the text defs without the ones that can be taken from the referenced message, and the indexes of those in the original defs.
+// If no defs can be taken from the referenced message then nil is returned.
+func bindReply(text *textArgDefs, data *Data) (*textArgDefs, []int) {
+	var fromReply []int
+	isFromReply := make([]bool, len(text.defs))
+	for i, def := range text.defs {
+		if _, _, ok := replyPart(def, data); ok {
+			fromReply = append(fromReply, text.indexes[i])
+			isFromReply[i] = true
+		}
+	}
+
+	if fromReply == nil {
+		return nil, nil
+	}
+
+	reply := &textArgDefs{defs: make([]*ArgDef, 0, len(text.defs)), indexes: make([]int, 0, len(text.defs))}
+	replyIndex := make([]int, len(text.defs))
+	for i, def := range text.defs {
+		if isFromReply[i] {
+			replyIndex[i] = -1
+			continue
+		}
+
+		replyIndex[i] = len(reply.defs)
+		reply.defs = append(reply.defs, def)
+		reply.indexes = append(reply.indexes, text.indexes[i])
+		if i < text.required {
+			reply.required++
+		}
+	}
+
+	if len(text.combos) > 0 {
+		// The args from the reply are optional
+		reply.combos = make([][]int, 0, len(text.combos))
+		for _, combo := range text.combos {
+			replyCombo := make([]int, 0, len(combo))
+			for _, v := range combo {
+				if replyIndex[v] != -1 {
+					replyCombo = append(replyCombo, replyIndex[v])
+				}
+			}
+
+			reply.combos = append(reply.combos, replyCombo)
+		}
+	}
+
+	return reply, fromReply
+}
+
+// parseWithReply parses the args of a command that was ran as a reply.
+// The args that were given take precedence, so the defs in fromReply are only taken from the referenced message
+// if the args don't match or parse with them included, e.g "!warn Bob reason" warns Bob while "!warn reason" warns the author of the referenced message
+func parseWithReply(defs []*ArgDef, text *textArgDefs, match *ComboMatch, reply *textArgDefs, fromReply []int, data *Data, split []*RawArg, parsedArgs []*ParsedArg, provided []bool) error {
+	var givenErr error
+	if match != nil {
+		args, argsProvided := cloneParsedArgs(parsedArgs, provided)
+		givenErr = parseMatch(defs, text, match, data, split, args, argsProvided)
+		if givenErr == nil {
+			for i, v := range args {
+				*parsedArgs[i] = *v
+			}
+			copy(provided, argsProvided)
+			return nil
+		}
+	}
+
+	replyMatch, candidates := MatchCombos(reply.defs, reply.required, reply.combos, split)
+	if replyMatch == nil && givenErr != nil {
+		return givenErr
+	}
+
+	for _, i := range fromReply {
+		val, err := parseReplyValue(defs[i], data)
+		if err != nil {
+			if givenErr != nil {
+				return givenErr
+			}
+
+			return NewParseError(err, defs[i], data.MsgStrippedPrefix, nil, 0)
+		}
+
+		parsedArgs[i].Value = val
+		provided[i] = true
+	}
+
+	err := parseText(defs, reply, replyMatch, candidates, data, split, parsedArgs, provided)
+	if err != nil && givenErr != nil {
+		// Show why the args that were given failed instead
+		return givenErr
+	}
+
+	return err
+}
+
+func cloneParsedArgs(parsedArgs []*ParsedArg, provided []bool) ([]*ParsedArg, []bool) {
+	cop := make([]*ParsedArg, len(parsedArgs))
+	for i, v := range parsedArgs {
+		arg := *v
+		cop[i] = &arg
+	}
+
+	return cop, append([]bool(nil), provided...)
+}
@@ -0,0 +1,147 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/jonas747/dstate"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"regexp"
+	"testing"
+)
+
+func testReplyData(input string) *Data {
+	offender := &discordgo.User{ID: 105487308693757952, Username: "offender"}
+	other := &discordgo.User{ID: 1, Username: "other"}
+	return &Data{
+		MsgStrippedPrefix: input,
+		Msg: &discordgo.Message{
+			ChannelID:         2,
+			Content:           input,
+			Mentions:          []*discordgo.User{other},
+			MessageReference:  &discordgo.MessageReference{MessageID: 3},
+			ReferencedMessage: &discordgo.Message{ID: 3, ChannelID: 2, Author: offender, Content: "bad words"},
+		},
+	}
+}
+
+func TestReplyArgs(t *testing.T) {
+	defs := []*ArgDef{
+		{Name: "user", Type: User, FromReply: ReplyAuthor},
+		{Name: "reason", Type: Remainder},
+	}
+
+	data := testReplyData("being rude")
+	err := ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "offender", data.Args[0].User().Username)
+		assert.Equal(t, "being rude", data.Args[1].Str())
+	}
+
+	data = testReplyData("<@1> being rude")
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "other", data.Args[0].User().Username, "a mention should override the reply")
+		assert.Equal(t, "being rude", data.Args[1].Str())
+	}
+
+	data = testReplyData("")
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.Error(t, err) {
+		assert.Equal(t, "reason", err.(*ParseError).ArgName)
+	}
+
+	data = testReplyData("being rude")
+	data.Msg.MessageReference = nil
+	data.Msg.ReferencedMessage = nil
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	assert.Error(t, err, "should not use the reply without one")
+}
+
+func TestReplyContentAndMessage(t *testing.T) {
+	defs := []*ArgDef{
+		{Name: "message", Type: Message, FromReply: ReplyMessage},
+		{Name: "text", Type: Remainder, FromReply: ReplyContent},
+	}
+
+	data := testReplyData("")
+	err := ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		ref := data.Args[0].MessageRef()
+		if assert.NotNil(t, ref) {
+			assert.Equal(t, int64(3), ref.MessageID)
+			assert.Equal(t, int64(2), ref.ChannelID)
+			assert.Equal(t, "bad words", ref.Message.Content)
+		}
+		assert.Equal(t, "bad words", data.Args[1].Str())
+	}
+
+	data = testReplyData("4 some text")
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(4), data.Args[0].MessageRef().MessageID)
+		assert.Equal(t, "some text", data.Args[1].Str())
+	}
+}
+
+func TestReplyArgsGivenFirst(t *testing.T) {
+	defs := []*ArgDef{
+		{Name: "user", Type: User, FromReply: ReplyAuthor},
+		{Name: "reason", Type: Remainder},
+	}
+
+	data := testReplyData("Bob being rude")
+	data.GS = &dstate.GuildState{Members: map[int64]*dstate.MemberState{5: {ID: 5, Username: "Bob"}}}
+	err := ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Bob", data.Args[0].User().Username, "a name that was given should override the reply")
+		assert.Equal(t, "being rude", data.Args[1].Str())
+	}
+
+	data = testReplyData("Alice being rude")
+	data.GS = &dstate.GuildState{Members: map[int64]*dstate.MemberState{5: {ID: 5, Username: "Bob"}}}
+	err = ParseArgDefs(defs, 2, nil, data, SplitArgs(data.MsgStrippedPrefix))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "offender", data.Args[0].User().Username)
+		assert.Equal(t, "Alice being rude", data.Args[1].Str())
+	}
+}
+
+func TestReplyArgsParsed(t *testing.T) {
+	cases := []struct {
+		name    string
+		def     *ArgDef
+		content string
+		err     error
+	}{
+		{"mass mention", &ArgDef{Name: "text", Type: &StringArg{MassMentions: MassMentionsReject}}, "hi @everyone", &MassMentionNotAllowed{ArgName: "text"}},
+		{"max length", &ArgDef{Name: "text", Type: &StringArg{MaxLen: 3}}, "hello", &InvalidLength{ArgName: "text", Got: 5, Max: 3}},
+		{"pattern", &ArgDef{Name: "text", Type: &StringArg{Pattern: regexp.MustCompile(`^\d+$`)}}, "hello", &PatternMismatch{ArgName: "text", Part: "hello"}},
+		{"valid", &ArgDef{Name: "text", Type: &StringArg{MaxLen: 10}}, "hello", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.def.FromReply = ReplyContent
+			data := testReplyData("")
+			data.Msg.ReferencedMessage.Content = c.content
+			err := ParseArgDefs([]*ArgDef{c.def}, 1, nil, data, nil)
+			if c.err == nil {
+				if assert.NoError(t, err) {
+					assert.Equal(t, c.content, data.Args[0].Str())
+				}
+				return
+			}
+
+			assert.Equal(t, c.err, errors.Cause(err))
+		})
+	}
+
+	// The member is looked up like for a mention
+	defs := []*ArgDef{{Name: "member", Type: &AdvUserArg{RequireMembership: true}, FromReply: ReplyAuthor}}
+	data := testReplyData("")
+	data.GS = &dstate.GuildState{Members: map[int64]*dstate.MemberState{105487308693757952: {ID: 105487308693757952, Username: "offender"}}}
+	err := ParseArgDefs(defs, 1, nil, data, nil)
+	if assert.NoError(t, err) {
+		assert.NotNil(t, data.Args[0].AdvUser().Member)
+	}
+}
@@ -15,6 +15,9 @@ type System struct {
 	Prefix         PrefixProvider
 	ResponseSender ResponseSender
 	State          *dstate.State
+
+	// Tokenizer splits the args for the standard argument parser, if nil then a StdTokenizer with the default settings is used
+	Tokenizer Tokenizer
 }
 
 func NewStandardSystem(staticPrefix string) (system *System) {
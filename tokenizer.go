@@ -0,0 +1,322 @@
+package dcmd
+
+import (
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Tokenizer splits the input of a command into args for the standard argument parser
+type Tokenizer interface {
+	Split(in string) []*RawArg
+}
+
+// AppendTokenizer can optionally be implemented by a Tokenizer to append the args to a reused slice,
+// ParseCmdArgs then uses pooled buffers instead of allocating new args for every command. See StdTokenizer.Tokenize
+type AppendTokenizer interface {
+	Tokenizer
+	Tokenize(in string, dst []RawArg) []RawArg
+}
+
+// rawArgsBuffer is a reusable buffer for the args of a AppendTokenizer
+type rawArgsBuffer struct {
+	values []RawArg
+	ptrs   []*RawArg
+}
+
+// maxPooledArgs is the max number of args in a buffer returned to the pool, so one huge message does not stay around
+const maxPooledArgs = 256
+
+var rawArgsPool = sync.Pool{
+	New: func() interface{} {
+		return &rawArgsBuffer{
+			values: make([]RawArg, 0, 16),
+			ptrs:   make([]*RawArg, 0, 16),
+		}
+	},
+}
+
+// tokenize splits in using the buffer, the returned args are only valid until the buffer is put back into the pool
+func (b *rawArgsBuffer) tokenize(t AppendTokenizer, in string) []*RawArg {
+	b.values = t.Tokenize(in, b.values[:0])
+	b.ptrs = b.ptrs[:0]
+	for i := range b.values {
+		b.ptrs = append(b.ptrs, &b.values[i])
+	}
+
+	return b.ptrs
+}
+
+func (b *rawArgsBuffer) release() {
+	if cap(b.values) > maxPooledArgs {
+		return
+	}
+
+	// Don't keep the strings of the message alive
+	for i := range b.values {
+		b.values[i] = RawArg{}
+	}
+	for i := range b.ptrs {
+		b.ptrs[i] = nil
+	}
+
+	rawArgsPool.Put(b)
+}
+
+var defaultTokenizer = NewStdTokenizer()
+
+// DefaultContainers returns the containers used by NewStdTokenizer, including the smart quotes mobile keyboards use.
+// A new map is returned on every call, so it can be modified without affecting other tokenizers
+func DefaultContainers() map[rune]rune {
+	return map[rune]rune{
+		'"': '"',
+		'`': '`',
+		'“': '”',
+		'‘': '’',
+		'„': '“',
+		'«': '»',
+	}
+}
+
+// StdTokenizer splits args by spaces, args can be grouped using containers (such as quotes),
+// and containers and spaces can be escaped using the escape characters
+type StdTokenizer struct {
+	// Containers maps the opening rune to the closing rune
+	Containers map[rune]rune
+
+	// Escape characters, an escape character can be escaped by itself
+	EscapeChars []rune
+
+	// Group ```code blocks``` into a single arg including newlines, the language tag is stripped and put in RawArg.Lang
+	CodeBlocks bool
+}
+
+var _ AppendTokenizer = (*StdTokenizer)(nil)
+
+// NewStdTokenizer returns a StdTokenizer with the default containers, escape character and code blocks enabled
+func NewStdTokenizer() *StdTokenizer {
+	return &StdTokenizer{
+		Containers:  DefaultContainers(),
+		EscapeChars: []rune{'\\'},
+		CodeBlocks:  true,
+	}
+}
+
+func (t *StdTokenizer) isEscape(r rune) bool {
+	for _, v := range t.EscapeChars {
+		if v == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+// opening returns the closing rune if r opens a container at this point
+func (t *StdTokenizer) opening(r rune, container rune, tok *token) (rune, bool) {
+	if container != 0 || !tok.empty() {
+		return 0, false
+	}
+
+	c, ok := t.Containers[r]
+	return c, ok
+}
+
+// Split implements Tokenizer
+func (t *StdTokenizer) Split(in string) []*RawArg {
+	args := t.Tokenize(in, nil)
+
+	rawArgs := make([]*RawArg, len(args))
+	for i := range args {
+		rawArgs[i] = &args[i]
+	}
+
+	return rawArgs
+}
+
+// Tokenize splits in and appends the args to dst, returning the extended slice.
+// The Str of the args are slices of in unless escape characters had to be removed,
+// so passing in a reused dst makes tokenizing mostly allocation free.
+//
+// Invalid UTF-8 in the input is kept as is in the args, older versions replaced every invalid byte with utf8.RuneError.
+// It's still decoded as utf8.RuneError while tokenizing, so it never acts as a space, container or escape character.
+func (t *StdTokenizer) Tokenize(in string, dst []RawArg) []RawArg {
+	if dst == nil {
+		dst = make([]RawArg, 0, 4)
+	}
+
+	var tok token
+	escape := false
+	escapeStart := 0
+	start := -1
+	var container, closing rune
+	for i := 0; i < len(in); {
+		r, size := utf8.DecodeRuneInString(in[i:])
+
+		if start == -1 && r != ' ' {
+			start = i
+		}
+
+		if t.CodeBlocks && !escape && container == 0 && tok.empty() && strings.HasPrefix(in[i:], "```") {
+			if arg, ok := t.codeBlock(in, i); ok {
+				dst = append(dst, arg)
+				i = arg.End
+				start = -1
+				continue
+			}
+		}
+		pos := i
+		i += size
+
+		// Apply or remove escape mode
+		if t.isEscape(r) {
+			if escape {
+				escape = false
+				tok.add(in, pos, i)
+			} else {
+				escape = true
+				escapeStart = pos
+			}
+
+			continue
+		}
+
+		// Check for other special tokens
+		isSpecialToken := true
+		if r == ' ' {
+			// Maybe seperate by space
+			if !tok.empty() && container == 0 && !escape {
+				dst = append(dst, RawArg{Str: tok.str(in), Start: start, End: pos})
+				tok.reset()
+			} else if !tok.empty() {
+				tok.add(in, pos, i)
+			}
+		} else if r == closing && container != 0 {
+			// Split arg here
+			if escape {
+				tok.add(in, pos, i)
+			} else {
+				dst = append(dst, RawArg{Str: tok.str(in), Container: container, Start: start, End: i})
+				tok.reset()
+				container = 0
+				closing = 0
+			}
+		} else if c, ok := t.opening(r, container, &tok); ok {
+			// Start containing a arg
+			if escape {
+				tok.add(in, pos, i)
+			} else {
+				container = r
+				closing = c
+			}
+		} else {
+			isSpecialToken = false
+		}
+
+		if !isSpecialToken {
+			if escape {
+				// Not escaping anything, so keep the escape character
+				tok.add(in, escapeStart, i)
+			} else {
+				tok.add(in, pos, i)
+			}
+		}
+
+		// Reset escape mode
+		escape = false
+
+		if tok.empty() && container == 0 {
+			// Nothing to start a new arg from yet
+			start = -1
+		}
+	}
+
+	// Something was left in the buffer just add it to the end
+	if !tok.empty() {
+		str := tok.str(in)
+		if container != 0 {
+			if tok.buf == nil && tok.start == start+utf8.RuneLen(container) {
+				str = in[start:tok.end]
+			} else {
+				str = string(container) + str
+			}
+		}
+		dst = append(dst, RawArg{Str: str, Start: start, End: len(in)})
+	}
+
+	return dst
+}
+
+// token is the arg currently being built by Tokenize, it refers to in[start:end] for as long as possible
+// and only copies into buf once something in the middle of it was left out (e.g an escape character)
+type token struct {
+	start, end int
+	buf        []byte
+	dirty      bool
+}
+
+func (t *token) empty() bool {
+	if t.dirty {
+		return len(t.buf) == 0
+	}
+
+	return t.start == t.end
+}
+
+// add appends in[from:to] to the token
+func (t *token) add(in string, from, to int) {
+	if !t.dirty {
+		if t.start == t.end {
+			t.start, t.end = from, to
+			return
+		}
+
+		if t.end == from {
+			t.end = to
+			return
+		}
+
+		t.dirty = true
+		t.buf = append(t.buf[:0], in[t.start:t.end]...)
+	}
+
+	t.buf = append(t.buf, in[from:to]...)
+}
+
+func (t *token) str(in string) string {
+	if t.dirty {
+		return string(t.buf)
+	}
+
+	return in[t.start:t.end]
+}
+
+// reset clears the token, keeping buf for reuse
+func (t *token) reset() {
+	t.start, t.end = 0, 0
+	t.buf = t.buf[:0]
+	t.dirty = false
+}
+
+// codeBlock returns the code block starting at in[start:], ok is false if it's not closed
+func (t *StdTokenizer) codeBlock(in string, start int) (arg RawArg, ok bool) {
+	end := strings.Index(in[start+3:], "```")
+	if end == -1 {
+		return arg, false
+	}
+
+	arg = RawArg{
+		Str:       in[start+3 : start+3+end],
+		Container: '`',
+		Start:     start,
+		End:       start + 3 + end + 3,
+	}
+
+	// The language tag is the first line, if the block has multiple lines and the first one has no spaces
+	if nl := strings.Index(arg.Str, "\n"); nl != -1 && !strings.ContainsAny(arg.Str[:nl], " \t") {
+		arg.Lang = arg.Str[:nl]
+		arg.Str = arg.Str[nl+1:]
+	}
+
+	return arg, true
+}
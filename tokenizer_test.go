@@ -0,0 +1,278 @@
+package dcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestStdTokenizer(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		strs  []string
+	}{
+		{"spaces", "hello  world", []string{"hello", "world"}},
+		{"quotes", `"hello world" hi`, []string{"hello world", "hi"}},
+		{"smart quotes", "“hello world” ‘a b’", []string{"hello world", "a b"}},
+		{"mismatched smart quotes", "“hello world\"", []string{"“hello world\""}},
+		{"escaped space", `hello\ world`, []string{"hello world"}},
+		{"escaped escape", `a\\b`, []string{`a\b`}},
+		{"code block", "```go\nfmt.Println(\"hi there\")\n``` after", []string{"fmt.Println(\"hi there\")\n", "after"}},
+		{"code block no lang", "```a b```", []string{"a b"}},
+		{"unclosed code block", "```a b", []string{"", "`a b"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			split := NewStdTokenizer().Split(c.input)
+			strs := make([]string, len(split))
+			for i, v := range split {
+				strs[i] = v.Str
+			}
+			assert.Equal(t, c.strs, strs)
+		})
+	}
+}
+
+func TestStdTokenizerCodeBlock(t *testing.T) {
+	in := "x ```py\nprint(1)```"
+	split := NewStdTokenizer().Split(in)
+	if assert.Len(t, split, 2) {
+		assert.Equal(t, "py", split[1].Lang)
+		assert.Equal(t, '`', split[1].Container)
+		assert.Equal(t, in[split[1].Start:split[1].End], "```py\nprint(1)```")
+	}
+}
+
+func TestCustomTokenizer(t *testing.T) {
+	tokenizer := &StdTokenizer{Containers: map[rune]rune{'(': ')'}, EscapeChars: []rune{'^'}}
+	split := tokenizer.Split(`(a "b) ^(c`)
+	strs := make([]string, len(split))
+	for i, v := range split {
+		strs[i] = v.Str
+	}
+	assert.Equal(t, []string{`a "b`, "(c"}, strs)
+}
+
+func TestTokenizerContainersNotShared(t *testing.T) {
+	tokenizer := NewStdTokenizer()
+	tokenizer.Containers['('] = ')'
+	delete(tokenizer.Containers, '"')
+
+	assert.Len(t, SplitArgs(`(a b) "c d"`), 3, "The default tokenizer should not be affected")
+	_, ok := DefaultContainers()['(']
+	assert.False(t, ok)
+}
+
+var tokenizerSeeds = []string{
+	"",
+	"hello world",
+	`"hello world" hi`,
+	"“hello world” ‘a b’ «c» „d“",
+	`hello\ world \"quoted\" \\ \n`,
+	`"unclosed quote`,
+	`"  leading spaces" \  x`,
+	"```go\nfmt.Println(\"hi\")\n``` after ```a b``` ```unclosed",
+	"<@105487308693757952> 10 -switch=value --flag \"some reason\"",
+}
+
+func TestTokenizeEquivalence(t *testing.T) {
+	tokenizer := NewStdTokenizer()
+	for _, in := range tokenizerSeeds {
+		assert.Equal(t, referenceSplit(tokenizer, in), tokenizer.Split(in), "input: %q", in)
+	}
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, v := range tokenizerSeeds {
+		f.Add(v)
+	}
+
+	tokenizer := NewStdTokenizer()
+	f.Fuzz(func(t *testing.T, in string) {
+		expected := referenceSplit(tokenizer, in)
+		got := tokenizer.Split(in)
+		if !assert.Equal(t, len(expected), len(got), "input: %q", in) {
+			return
+		}
+
+		for i := range expected {
+			// the reference replaces every invalid byte with utf8.RuneError while Tokenize keeps the input as is,
+			// code blocks are sliced from the input in both
+			want, arg := *expected[i], *got[i]
+			want.Str, arg.Str = replaceInvalidUTF8(want.Str), replaceInvalidUTF8(arg.Str)
+			assert.Equal(t, want, arg, "input: %q", in)
+		}
+	})
+}
+
+func TestTokenizeInvalidUTF8(t *testing.T) {
+	in := "a\xff\xfe \"b \xff\" ```\xff```"
+	split := NewStdTokenizer().Split(in)
+	if assert.Len(t, split, 3) {
+		assert.Equal(t, "a\xff\xfe", split[0].Str)
+		assert.Equal(t, "b \xff", split[1].Str)
+		assert.Equal(t, "\xff", split[2].Str)
+	}
+}
+
+// replaceInvalidUTF8 replaces every invalid byte with utf8.RuneError, like the reference tokenizer does
+func replaceInvalidUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		b.WriteRune(r)
+		i += size
+	}
+
+	return b.String()
+}
+
+var benchmarkInput = `<@105487308693757952> 10 -switch=value --flag "some reason with spaces" and then a bunch more words\ here`
+
+func BenchmarkTokenize(b *testing.B) {
+	tokenizer := NewStdTokenizer()
+	dst := make([]RawArg, 0, 16)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dst = tokenizer.Tokenize(benchmarkInput, dst[:0])
+	}
+}
+
+func BenchmarkSplitArgs(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SplitArgs(benchmarkInput)
+	}
+}
+
+func BenchmarkParseCmdArgs(b *testing.B) {
+	cmd := &RegisteredCommand{Command: &benchmarkCmd{}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		data := &Data{MsgStrippedPrefix: benchmarkInput, Cmd: cmd}
+		if err := ParseCmdArgs(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type benchmarkCmd struct{}
+
+func (b *benchmarkCmd) Run(data *Data) (interface{}, error) { return nil, nil }
+func (b *benchmarkCmd) ArgDefs(data *Data) ([]*ArgDef, int, [][]int) {
+	return []*ArgDef{{Type: UserID}, {Type: Int}, {Type: Remainder}}, 3, nil
+}
+func (b *benchmarkCmd) Switches() []*ArgDef {
+	return []*ArgDef{{Switch: "switch", Type: String}, {Switch: "flag"}}
+}
+
+func BenchmarkReferenceSplit(b *testing.B) {
+	tokenizer := NewStdTokenizer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		referenceSplit(tokenizer, benchmarkInput)
+	}
+}
+
+// referenceSplit is the original string concatenating tokenizer, Tokenize has to produce the same args
+func referenceSplit(t *StdTokenizer, in string) []*RawArg {
+	rawArgs := make([]*RawArg, 0)
+
+	curBuf := ""
+	escape := false
+	start := -1
+	var container, closing rune
+	for i := 0; i < len(in); {
+		r, size := utf8.DecodeRuneInString(in[i:])
+
+		if start == -1 && r != ' ' {
+			start = i
+		}
+
+		if t.CodeBlocks && !escape && container == 0 && curBuf == "" && strings.HasPrefix(in[i:], "```") {
+			if arg, ok := t.codeBlock(in, i); ok {
+				rawArgs = append(rawArgs, &arg)
+				i = arg.End
+				start = -1
+				continue
+			}
+		}
+		i += size
+
+		// Apply or remove escape mode
+		if t.isEscape(r) {
+			if escape {
+				escape = false
+				curBuf += string(r)
+			} else {
+				escape = true
+			}
+
+			continue
+		}
+
+		// Check for other special tokens
+		isSpecialToken := true
+		if r == ' ' {
+			// Maybe seperate by space
+			if curBuf != "" && container == 0 && !escape {
+				rawArgs = append(rawArgs, &RawArg{Str: curBuf, Start: start, End: i - size})
+				curBuf = ""
+			} else if curBuf != "" {
+				curBuf += " "
+			}
+		} else if r == closing && container != 0 {
+			// Split arg here
+			if escape {
+				curBuf += string(r)
+			} else {
+				rawArgs = append(rawArgs, &RawArg{Str: curBuf, Container: container, Start: start, End: i})
+				curBuf = ""
+				container = 0
+				closing = 0
+			}
+		} else if c, ok := t.Containers[r]; ok && container == 0 && curBuf == "" {
+			// Start containing a arg
+			if escape {
+				curBuf += string(r)
+			} else {
+				container = r
+				closing = c
+			}
+		} else {
+			isSpecialToken = false
+		}
+
+		if !isSpecialToken {
+			if escape {
+				curBuf += "\\"
+			}
+			curBuf += string(r)
+		}
+
+		// Reset escape mode
+		escape = false
+
+		if curBuf == "" && container == 0 {
+			// Nothing to start a new arg from yet
+			start = -1
+		}
+	}
+
+	// Something was left in the buffer just add it to the end
+	if curBuf != "" {
+		if container != 0 {
+			curBuf = string(container) + curBuf
+		}
+		rawArgs = append(rawArgs, &RawArg{Str: curBuf, Start: start, End: len(in)})
+	}
+
+	return rawArgs
+}
@@ -1,8 +1,14 @@
 package dcmd
 
+import (
+	"sync/atomic"
+)
+
 type Trigger struct {
-	Names       []string
-	Middlewares []MiddleWareFunc
+	Names []string
+
+	// The *triggerMiddlewares set by SetMiddlewares
+	mws atomic.Value
 
 	HideFromHelp     bool
 	DisableInDM      bool
@@ -35,7 +41,31 @@ func (t *Trigger) SetDisableOutsideDM(disable bool) *Trigger {
 	return t
 }
 
+// triggerMiddlewares are the middlewares of a trigger, they're replaced as a whole so that they can be read while they're being set.
+// The pointer also identifies what the middlewares were when a chain was built, see RegisteredCommand.middlewareChain
+type triggerMiddlewares struct {
+	funcs []MiddleWareFunc
+}
+
+// SetMiddlewares sets the middlewares that are ran for the command after the middlewares of the containers,
+// it's safe to call while the command is being ran
 func (t *Trigger) SetMiddlewares(mw ...MiddleWareFunc) *Trigger {
-	t.Middlewares = mw
+	t.mws.Store(&triggerMiddlewares{funcs: append([]MiddleWareFunc(nil), mw...)})
 	return t
 }
+
+// Middlewares returns the middlewares set with SetMiddlewares
+func (t *Trigger) Middlewares() []MiddleWareFunc {
+	mws := t.middlewares()
+	if mws == nil {
+		return nil
+	}
+
+	return append([]MiddleWareFunc(nil), mws.funcs...)
+}
+
+// middlewares returns the current middlewares, or nil if none are set
+func (t *Trigger) middlewares() *triggerMiddlewares {
+	mws, _ := t.mws.Load().(*triggerMiddlewares)
+	return mws
+}
@@ -0,0 +1,156 @@
+package dcmd
+
+import (
+	"github.com/pkg/errors"
+	"reflect"
+)
+
+// TypedArgType can optionally be implemented by arg types to declare the type of the values they parse,
+// which is used to check the type of typed args (see NewArg)
+type TypedArgType interface {
+	ValueType() reflect.Type
+}
+
+// Arg is a ArgDef with the type of its value known at compile time, so that the value can be fetched from Data
+// without looking it up by index and asserting the type
+//
+//	var amountArg = dcmd.MustArg[int64](&dcmd.ArgDef{Name: "Amount", Type: dcmd.Int})
+//	...
+//	amount := amountArg.Get(data)
+//
+// Variadic args have a slice type, e.g Arg[[]int64]
+//
+// The type is only checked when the arg is created with NewArg or MustArg, a Arg created directly or with a arg type
+// that doesn't implement TypedArgType is not checked, and Get then returns the zero value if the value is not a T.
+// Use WithDefault to compute the default from Data, e.g
+//
+//	var targetArg = dcmd.MustArg[*dcmd.AdvUserMatch](&dcmd.ArgDef{Name: "Target", Type: dcmd.AdvUser}).WithDefault(func(data *dcmd.Data) (*dcmd.AdvUserMatch, error) {
+//		return &dcmd.AdvUserMatch{User: data.Msg.Author}, nil
+//	})
+//
+// Typed args use generics, and so require Go 1.18 or newer
+type Arg[T any] struct {
+	Def *ArgDef
+}
+
+// NewArg creates a typed arg from def, returning a error if def.Type (see TypedArgType) or def.Default does not produce values of type T.
+// Values taken from replies (see ArgDef.FromReply) are parsed by def.Type so they're checked with it, but the values returned
+// by def.DefaultFunc can't be checked so it has to be set with WithDefault instead
+func NewArg[T any](def *ArgDef) (*Arg[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+
+	valueType := typ
+	if def.Variadic {
+		if typ.Kind() != reflect.Slice {
+			return nil, errors.Errorf("Arg %s is variadic, so its type has to be a slice, not %s", argName(def), typ)
+		}
+		valueType = typ.Elem()
+	}
+
+	if def.Type == nil {
+		// switches without a type are booleans
+		if def.Switch != "" && valueType.Kind() != reflect.Bool {
+			return nil, errors.Errorf("Switch %s has no type, so its values are bool, not %s", argName(def), valueType)
+		}
+	} else if t, ok := def.Type.(TypedArgType); ok {
+		if !t.ValueType().AssignableTo(valueType) {
+			return nil, errors.Errorf("Arg %s (%s) has values of type %s, not %s", argName(def), def.Type.HelpName(), t.ValueType(), valueType)
+		}
+	}
+
+	if def.Default != nil && !reflect.TypeOf(def.Default).AssignableTo(typ) {
+		return nil, errors.Errorf("Default of arg %s is a %T, not %s", argName(def), def.Default, typ)
+	}
+
+	if def.DefaultFunc != nil {
+		return nil, errors.Errorf("Arg %s has a DefaultFunc that can't be checked to return values of type %s, use WithDefault instead", argName(def), typ)
+	}
+
+	return &Arg[T]{Def: def}, nil
+}
+
+// MustArg is like NewArg but panics on errors, for use in package level variables
+func MustArg[T any](def *ArgDef) *Arg[T] {
+	a, err := NewArg[T](def)
+	if err != nil {
+		panic(err)
+	}
+
+	return a
+}
+
+// WithDefault sets the DefaultFunc of the arg to fn, which is called to get the value if the arg was omitted
+func (a *Arg[T]) WithDefault(fn func(data *Data) (T, error)) *Arg[T] {
+	a.Def.DefaultFunc = func(data *Data) (interface{}, error) {
+		v, err := fn(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	}
+
+	return a
+}
+
+// Get returns the value of the arg, or the zero value if it was not provided and has no default
+func (a *Arg[T]) Get(data *Data) T {
+	v, _ := a.Lookup(data)
+	return v
+}
+
+// Lookup returns the value of the arg, ok is false if the arg did not have a value (of type T)
+func (a *Arg[T]) Lookup(data *Data) (v T, ok bool) {
+	p := a.Parsed(data)
+	if p == nil {
+		return v, false
+	}
+
+	return Value[T](p)
+}
+
+// Parsed returns the ParsedArg of this arg from either the args or the switches, or nil if it's not a arg of the command
+func (a *Arg[T]) Parsed(data *Data) *ParsedArg {
+	if a.Def.Switch != "" {
+		if p, ok := data.Switches[a.Def.Switch]; ok && p.Def == a.Def {
+			return p
+		}
+	}
+
+	for _, v := range data.Args {
+		if v.Def == a.Def {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// Value returns the value of p as a T, ok is false if the value is nil or not a T
+// []interface{} values of variadic args are converted to a slice of T's element type
+func Value[T any](p *ParsedArg) (v T, ok bool) {
+	if p == nil || p.Value == nil {
+		return v, false
+	}
+
+	if v, ok = p.Value.(T); ok {
+		return v, true
+	}
+
+	vals, isSlice := p.Value.([]interface{})
+	typ := reflect.TypeOf(v)
+	if !isSlice || typ == nil || typ.Kind() != reflect.Slice {
+		return v, false
+	}
+
+	out := reflect.MakeSlice(typ, len(vals), len(vals))
+	for i, val := range vals {
+		rv := reflect.ValueOf(val)
+		if !rv.IsValid() || !rv.Type().AssignableTo(typ.Elem()) {
+			return v, false
+		}
+		out.Index(i).Set(rv)
+	}
+
+	return out.Interface().(T), true
+}
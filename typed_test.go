@@ -0,0 +1,116 @@
+package dcmd
+
+import (
+	"github.com/jonas747/discordgo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewArgTypeCheck(t *testing.T) {
+	_, err := NewArg[int64](&ArgDef{Name: "amount", Type: Int})
+	assert.NoError(t, err)
+
+	_, err = NewArg[string](&ArgDef{Name: "amount", Type: Int})
+	if assert.Error(t, err) {
+		assert.Equal(t, "Arg amount (Whole number) has values of type int64, not string", err.Error())
+	}
+
+	_, err = NewArg[int64](&ArgDef{Name: "amount", Type: Int, Default: 5})
+	assert.Error(t, err, "default is a int")
+
+	_, err = NewArg[int64](&ArgDef{Name: "amounts", Type: Int, Variadic: true})
+	assert.Error(t, err, "variadic args need a slice type")
+
+	_, err = NewArg[[]int64](&ArgDef{Name: "amounts", Type: Int, Variadic: true})
+	assert.NoError(t, err)
+
+	_, err = NewArg[bool](&ArgDef{Switch: "force"})
+	assert.NoError(t, err)
+
+	_, err = NewArg[*discordgo.User](&ArgDef{Name: "user", Type: User, DefaultFunc: DefaultInvoker})
+	assert.Error(t, err, "DefaultFunc can't be checked")
+}
+
+func TestArgWithDefault(t *testing.T) {
+	author := &discordgo.User{ID: 1, Username: "author"}
+	user := MustArg[*discordgo.User](&ArgDef{Name: "user", Type: User}).WithDefault(func(data *Data) (*discordgo.User, error) {
+		return data.Msg.Author, nil
+	})
+
+	data := &Data{Msg: &discordgo.Message{Author: author}}
+	assert.NoError(t, ParseArgDefs([]*ArgDef{user.Def}, 0, nil, data, nil))
+	assert.Equal(t, author, user.Get(data))
+
+	// Values from replies are parsed by the type
+	reply := MustArg[*discordgo.User](&ArgDef{Name: "user", Type: User, FromReply: ReplyAuthor})
+	data = testReplyData("")
+	assert.NoError(t, ParseArgDefs([]*ArgDef{reply.Def}, 1, nil, data, nil))
+	if v := reply.Get(data); assert.NotNil(t, v) {
+		assert.Equal(t, "offender", v.Username)
+	}
+}
+
+func TestArgGet(t *testing.T) {
+	amount := MustArg[int64](&ArgDef{Name: "amount", Type: Int})
+	ids := MustArg[[]int64](&ArgDef{Name: "ids", Type: Int, Variadic: true})
+	duration := MustArg[time.Duration](&ArgDef{Switch: "d", Type: Duration})
+	force := MustArg[bool](&ArgDef{Switch: "f"})
+
+	data := &Data{MsgStrippedPrefix: "10 1 2 3"}
+	split := SplitArgs(data.MsgStrippedPrefix)
+	assert.NoError(t, ParseArgDefs([]*ArgDef{amount.Def, ids.Def}, 2, nil, data, split))
+
+	split = SplitArgs("-d 1h30m")
+	_, err := ParseSwitches([]*ArgDef{duration.Def, force.Def}, data, split)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(10), amount.Get(data))
+	assert.Equal(t, []int64{1, 2, 3}, ids.Get(data))
+	assert.Equal(t, time.Hour+time.Minute*30, duration.Get(data))
+	assert.Equal(t, false, force.Get(data))
+
+	_, ok := MustArg[int64](&ArgDef{Name: "other", Type: Int}).Lookup(data)
+	assert.False(t, ok, "not a arg of the command")
+}
+
+func TestDurationArg(t *testing.T) {
+	cases := []struct {
+		part     string
+		expected time.Duration
+	}{
+		{"1h30m", time.Hour + time.Minute*30},
+		{"2d", time.Hour * 48},
+		{"1.5w", time.Hour * 24 * 7 * 3 / 2},
+		{"10", time.Minute * 10},
+		{"5mins", time.Minute * 5},
+	}
+
+	for _, c := range cases {
+		v, err := Duration.Parse(&ArgDef{}, c.part, nil)
+		if assert.NoError(t, err, c.part) {
+			assert.Equal(t, c.expected, v, c.part)
+		}
+	}
+
+	assert.False(t, Duration.Matches(nil, "1x"))
+	assert.False(t, Duration.Matches(nil, "h"))
+
+	for _, part := range []string{"-10", "-1h", "inf", "nan", "1e30", "0x10", "99999999999w", "300000000000000000000", "106751d1d", "106751d23h48m"} {
+		_, err := Duration.Parse(&ArgDef{}, part, nil)
+		assert.IsType(t, &InvalidDuration{}, err, part)
+	}
+
+	v, err := Duration.Parse(&ArgDef{}, "106751d23h47m", nil)
+	if assert.NoError(t, err, "just below the max duration") {
+		assert.True(t, v.(time.Duration) > 0)
+	}
+
+	_, err = (&DurationArg{Max: time.Hour}).Parse(&ArgDef{Name: "time"}, "2h", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, "time is too big (has to be at most 1h0m0s)", err.Error())
+	}
+
+	_, err = (&DurationArg{Max: time.Hour}).Parse(nil, "2h", nil)
+	assert.Error(t, err, "Should not need a def")
+}
@@ -0,0 +1,191 @@
+package dcmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError is a problem with the command tree found by Container.Validate
+type ValidationError struct {
+	// The full name of the command or container with the problem, e.g "settings prefix"
+	Path    string
+	Problem string
+}
+
+func (v *ValidationError) Error() string {
+	if v.Path == "" {
+		return v.Problem
+	}
+
+	return v.Path + ": " + v.Problem
+}
+
+// ValidationErrors are all the problems found by Container.Validate
+type ValidationErrors []*ValidationError
+
+func (v ValidationErrors) Error() string {
+	lines := make([]string, len(v))
+	for i, err := range v {
+		lines[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d problem(s) with the command tree:\n", len(v)) + strings.Join(lines, "\n")
+}
+
+// Validate checks the command tree of the system, see Container.Validate
+func (sys *System) Validate() error {
+	return sys.Root.Validate()
+}
+
+// Validate walks the container and all its sub containers and checks for mistakes that would otherwise show up as panics
+// or misbehaviour at runtime, such as duplicate trigger names, invalid arg defs and switches.
+// It should be called at startup after all the commands have been added, and returns ValidationErrors if any problems were found.
+//
+// ArgDefs of CmdWithArgDefs commands are called with nil data, panics in it are reported as problems.
+func (c *Container) Validate() error {
+	var problems ValidationErrors
+	c.validate(&problems)
+	if len(problems) > 0 {
+		return problems
+	}
+
+	return nil
+}
+
+func (c *Container) validate(problems *ValidationErrors) {
+	containerPath := c.FullName(false)
+	report := func(path, format string, args ...interface{}) {
+		*problems = append(*problems, &ValidationError{Path: path, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Parent != nil && len(c.Names) < 1 {
+		report(containerPath, "container has no names")
+	}
+
+	seen := make(map[string]string)
+	for i, cmd := range c.ListCommands() {
+		if cmd == nil || cmd.Trigger == nil || len(cmd.Trigger.Names) < 1 {
+			report(containerPath, "command %d has no trigger names", i)
+			continue
+		}
+
+		path := strings.TrimSpace(containerPath + " " + cmd.Trigger.Names[0])
+		own := make(map[string]bool)
+		for _, name := range cmd.Trigger.Names {
+			if name == "" || strings.Contains(name, " ") {
+				report(path, "invalid trigger name %q", name)
+				continue
+			}
+
+			lower := strings.ToLower(name)
+			if own[lower] {
+				report(path, "trigger name %q is repeated", name)
+				continue
+			}
+			own[lower] = true
+
+			if other, ok := seen[lower]; ok {
+				report(path, "trigger name %q is already used by %s", name, other)
+				continue
+			}
+			seen[lower] = path
+		}
+
+		if cmd.Command == nil {
+			report(path, "command is nil")
+			continue
+		}
+
+		if sub, ok := cmd.Command.(*Container); ok {
+			sub.validate(problems)
+			continue
+		}
+
+		if cast, ok := cmd.Command.(CmdWithArgDefs); ok {
+			var defs []*ArgDef
+			var required int
+			var combos [][]int
+			if p := recoverPanic(func() { defs, required, combos = cast.ArgDefs(nil) }); p != nil {
+				report(path, "ArgDefs panicked when called with nil data: %v", p)
+			} else {
+				validateArgDefs(path, defs, required, combos, report)
+			}
+		}
+
+		if cast, ok := cmd.Command.(CmdWithSwitches); ok {
+			var switches []*ArgDef
+			if p := recoverPanic(func() { switches = cast.Switches() }); p != nil {
+				report(path, "Switches panicked: %v", p)
+			} else {
+				validateSwitches(path, switches, report)
+			}
+		}
+	}
+}
+
+// recoverPanic calls fn and returns what it panicked with, or nil if it didn't panic
+func recoverPanic(fn func()) (p interface{}) {
+	defer func() {
+		p = recover()
+	}()
+
+	fn()
+	return nil
+}
+
+func validateArgDefs(path string, defs []*ArgDef, required int, combos [][]int, report func(path, format string, args ...interface{})) {
+	if required < 0 || required > len(defs) {
+		report(path, "required is %d but there are %d arg defs", required, len(defs))
+	}
+
+	for i, def := range defs {
+		if def == nil {
+			report(path, "arg def %d is nil", i)
+		} else if def.Type == nil {
+			report(path, "arg %s has no Type", argName(def))
+		}
+	}
+
+	for i, combo := range combos {
+		for _, v := range combo {
+			if v < 0 || v >= len(defs) {
+				report(path, "combo %d references arg def %d, but there are %d arg defs", i, v, len(defs))
+			}
+		}
+	}
+}
+
+func validateSwitches(path string, switches []*ArgDef, report func(path, format string, args ...interface{})) {
+	seen := make(map[string]bool)
+	for i, sw := range switches {
+		if sw == nil {
+			report(path, "switch %d is nil", i)
+			continue
+		}
+
+		if sw.Switch == "" {
+			report(path, "switch %d (%s) has no Switch name", i, sw.Name)
+			continue
+		}
+
+		for _, name := range append([]string{sw.Switch}, sw.SwitchAliases...) {
+			if seen[name] {
+				report(path, "switch name %q is used more than once", name)
+				continue
+			}
+			seen[name] = true
+		}
+	}
+
+	for _, sw := range switches {
+		if sw == nil {
+			continue
+		}
+
+		for _, req := range sw.Requires {
+			if !seen[req] {
+				report(path, "switch %s requires unknown switch %q", sw.Switch, req)
+			}
+		}
+	}
+}
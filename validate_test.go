@@ -0,0 +1,62 @@
+package dcmd
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	system := NewStandardSystem("!")
+	assert.NoError(t, system.Validate())
+
+	system.Root.AddCommand(&TestCommand{}, NewTrigger("test", "t"))
+	system.Root.AddCommand(&TestCommand{}, NewTrigger("Test"))
+	system.Root.AddCommand(&SimpleCmd{
+		CmdArgDefs:      []*ArgDef{{Name: "a", Type: Int}, {Name: "b"}},
+		RequiredArgDefs: 3,
+		ArgDefCombos:    [][]int{{0, 2}},
+		CmdSwitches:     []*ArgDef{{Switch: "x", SwitchAliases: []string{"y"}, Requires: []string{"z"}}, {Switch: "y"}, {Name: "noswitch"}},
+	}, NewTrigger("args"))
+	system.Root.AddCommand(&TestCommand{}, NewTrigger("dup", "d", "D"))
+	system.Root.AddCommand(&dataArgDefsCmd{}, NewTrigger("data"))
+
+	sub := system.Root.Sub("sub")
+	sub.AddCommand(&TestCommand{}, NewTrigger("t"))
+	sub.AddCommand(&TestCommand{}, &Trigger{})
+
+	err := system.Validate()
+	if !assert.Error(t, err) {
+		return
+	}
+
+	problems := err.(ValidationErrors)
+	messages := make([]string, len(problems))
+	for i, v := range problems {
+		messages[i] = v.Error()
+	}
+
+	assert.Equal(t, []string{
+		`Test: trigger name "Test" is already used by test`,
+		`args: required is 3 but there are 2 arg defs`,
+		`args: arg b has no Type`,
+		`args: combo 0 references arg def 2, but there are 2 arg defs`,
+		`args: switch name "y" is used more than once`,
+		`args: switch 2 (noswitch) has no Switch name`,
+		`args: switch x requires unknown switch "z"`,
+		`dup: trigger name "D" is repeated`,
+		`data: ArgDefs panicked when called with nil data: runtime error: invalid memory address or nil pointer dereference`,
+		`sub: command 1 has no trigger names`,
+	}, messages)
+}
+
+// dataArgDefsCmd reads data in ArgDefs, which panics when validated
+type dataArgDefsCmd struct{}
+
+func (d *dataArgDefsCmd) Run(data *Data) (interface{}, error) { return nil, nil }
+func (d *dataArgDefsCmd) ArgDefs(data *Data) ([]*ArgDef, int, [][]int) {
+	if data.Msg.Author.Bot {
+		return nil, 0, nil
+	}
+
+	return []*ArgDef{{Type: Int}}, 1, nil
+}
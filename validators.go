@@ -0,0 +1,53 @@
+package dcmd
+
+import (
+	"github.com/jonas747/dstate"
+)
+
+// Some commonly used validators for ArgDef.Validators
+
+// ValidateNotInvoker fails if the user is the one who invoked the command
+func ValidateNotInvoker(data *Data, value interface{}) error {
+	user := (&ParsedArg{Value: value}).User()
+	if user == nil {
+		if id, ok := value.(int64); ok && id == data.Msg.Author.ID {
+			return NewSimpleUserError("Can't be yourself")
+		}
+
+		return nil
+	}
+
+	if user.ID == data.Msg.Author.ID {
+		return NewSimpleUserError("Can't be yourself")
+	}
+
+	return nil
+}
+
+// ValidateNotBot fails if the user is the bot itself
+func ValidateNotBot(data *Data, value interface{}) error {
+	user := (&ParsedArg{Value: value}).User()
+	if user == nil || data.Session == nil || data.Session.State.User == nil {
+		return nil
+	}
+
+	if user.ID == data.Session.State.User.ID {
+		return NewSimpleUserError("Can't be me")
+	}
+
+	return nil
+}
+
+// ValidateChannelInGuild fails if the channel is not in the server the command was invoked in
+func ValidateChannelInGuild(data *Data, value interface{}) error {
+	cs, ok := value.(*dstate.ChannelState)
+	if !ok {
+		return nil
+	}
+
+	if data.GS == nil || cs.Guild == nil || cs.Guild.ID != data.GS.ID {
+		return NewSimpleUserError("Has to be a channel in this server")
+	}
+
+	return nil
+}